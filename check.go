@@ -2,9 +2,9 @@ package irisx
 
 import (
 	"encoding/json"
+	"image"
 	"io"
 	"mime/multipart"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -12,16 +12,16 @@ import (
 	"time"
 
 	"github.com/asaskevich/govalidator"
-	"github.com/microcosm-cc/bluemonday"
 )
 
 type Validator struct {
 	ctx *Context
 	// params interface{}
-	key    string
-	value  string
-	exists bool
-	goon   bool
+	key       string
+	value     string
+	exists    bool
+	goon      bool
+	sanitized bool
 	// errors map[string]string
 	// isEmpty bool
 }
@@ -55,7 +55,7 @@ func NewValidatorFile(ctx *Context, key string, file multipart.File, header *mul
 		key:    key,
 		file:   file,
 		header: header,
-		exists: err != nil,
+		exists: err == nil,
 		goon:   true,
 		err:    err,
 		// errors: make(map[string]string),
@@ -263,7 +263,7 @@ func (v *Validator) IsNumeric(msg ...string) *Validator {
 	return v
 }
 func (v *Validator) IsTime(format string, msg ...string) *Validator {
-	if v.goon && !govalidator.IsTime(format, v.value) {
+	if v.goon && !govalidator.IsTime(v.value, format) {
 		v.addError(v.format(v.key+" is bad format", msg))
 	}
 	return v
@@ -301,15 +301,10 @@ func (v *Validator) String(dv ...string) string {
 	return dv[0]
 }
 
-var htmlSanitizer = bluemonday.UGCPolicy()
-
-//Int to int value
+//SanitizeHtml sanitize value with ugc policy, see Sanitize
 func (v *Validator) SanitizeHtml() string {
 	// if v.goon {
-	if len(v.value) == 0 {
-		return ""
-	}
-	return htmlSanitizer.Sanitize(v.value)
+	return SanitizeString(SanitizeUGC, v.value)
 	// }
 	// return v.value
 }
@@ -423,10 +418,11 @@ func (v *Validator) TimeShort(dv time.Time, msg ...string) time.Time {
 type ValidatorValues struct {
 	ctx *Context
 	// params interface{}
-	key    string
-	values []string
-	exists bool
-	goon   bool
+	key       string
+	values    []string
+	exists    bool
+	goon      bool
+	sanitized bool
 	// isEmpty bool
 	// errors map[string]string
 }
@@ -435,6 +431,20 @@ func (v *ValidatorValues) addError(msg string) {
 	v.goon = false
 	v.ctx.AddParamError(v.key, msg)
 }
+
+//addErrorAt add error for the i-th value, eg: tags[2]
+func (v *ValidatorValues) addErrorAt(i int, msg string) {
+	v.goon = false
+	v.ctx.AddParamError(v.keyAt(i), msg)
+}
+
+//own copy values before changing them, values may be shared with ctx.FormValues()
+func (v *ValidatorValues) own() {
+	v.values = append([]string(nil), v.values...)
+}
+func (v *ValidatorValues) keyAt(i int) string {
+	return v.key + "[" + strconv.Itoa(i) + "]"
+}
 func (v *ValidatorValues) hasError() bool {
 	return len(v.ctx.ParamErrors()) != 0
 }
@@ -444,6 +454,24 @@ func (v *ValidatorValues) format(defaultMsg string, msg []string) string {
 	}
 	return defaultMsg
 }
+
+//check add error for every value which not pass the test
+func (v *ValidatorValues) check(test func(value string) bool, defaultMsg string, msg []string) *ValidatorValues {
+	if !v.goon {
+		return v
+	}
+	failed := false
+	for i, x := range v.values {
+		if !test(x) {
+			v.ctx.AddParamError(v.keyAt(i), v.format(v.keyAt(i)+defaultMsg, msg))
+			failed = true
+		}
+	}
+	if failed {
+		v.goon = false
+	}
+	return v
+}
 func (v *ValidatorValues) Optional() *ValidatorValues {
 	if !v.exists {
 		v.goon = false
@@ -465,7 +493,14 @@ func (v *ValidatorValues) Empty(msg ...string) *ValidatorValues {
 	}
 	return v
 }
+func (v *ValidatorValues) Exist(msg ...string) *ValidatorValues {
+	if v.goon && !v.exists {
+		v.addError(v.format(v.key+" should exists.", msg))
+	}
+	return v
+}
 
+//Len length of values
 func (v *ValidatorValues) Len(min, max int, msg ...string) *ValidatorValues {
 	if v.goon {
 		if len(v.values) < min {
@@ -479,27 +514,38 @@ func (v *ValidatorValues) Len(min, max int, msg ...string) *ValidatorValues {
 	}
 	return v
 }
-func (v *ValidatorValues) Match(reg string, msg ...string) *ValidatorValues {
-	if v.goon {
-		for _, x := range v.values {
-			if !mustMatch(reg, x) {
-				v.addError(v.format(v.key+" is bad format.", msg))
-				return v
-			}
+
+//EachLen length of every value
+func (v *ValidatorValues) EachLen(min, max int, msg ...string) *ValidatorValues {
+	if !v.goon {
+		return v
+	}
+	failed := false
+	for i, x := range v.values {
+		if len(x) < min {
+			v.ctx.AddParamError(v.keyAt(i), v.format(v.keyAt(i)+"'s length must equal or great than "+strconv.Itoa(min), msg))
+			failed = true
+		} else if max > 0 && len(x) > max {
+			v.ctx.AddParamError(v.keyAt(i), v.format(v.keyAt(i)+"'s length must equal or less than "+strconv.Itoa(max), msg))
+			failed = true
 		}
 	}
+	if failed {
+		v.goon = false
+	}
 	return v
 }
+func (v *ValidatorValues) ByteLen(min, max int, msg ...string) *ValidatorValues {
+	return v.check(func(x string) bool { return govalidator.IsByteLength(x, min, max) }, "'s length no ok.", msg)
+}
+func (v *ValidatorValues) NotBlank(msg ...string) *ValidatorValues {
+	return v.check(func(x string) bool { return "" != x && !mustMatch("^\\s*$", x) }, " can not be blank.", msg)
+}
+func (v *ValidatorValues) Match(reg string, msg ...string) *ValidatorValues {
+	return v.check(func(x string) bool { return mustMatch(reg, x) }, " is bad format.", msg)
+}
 func (v *ValidatorValues) NotMatch(reg string, msg ...string) *ValidatorValues {
-	if v.goon {
-		for _, x := range v.values {
-			if mustMatch(reg, x) {
-				v.addError(v.format(v.key+" is bad format.", msg))
-				return v
-			}
-		}
-	}
-	return v
+	return v.check(func(x string) bool { return !mustMatch(reg, x) }, " is bad format.", msg)
 }
 func (v *ValidatorValues) Ensure(assertion, shouldBail bool, msg ...string) *ValidatorValues {
 	if shouldBail {
@@ -519,6 +565,153 @@ func (v *ValidatorValues) EnsureNot(assertion, shouldBail bool, msg ...string) *
 	}
 	return v
 }
+func (v *ValidatorValues) IsInt(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsInt, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsFloat(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsFloat, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsBool(msg ...string) *ValidatorValues {
+	return v.check(func(x string) bool {
+		_, err := strconv.ParseBool(x)
+		return err == nil
+	}, " is bad format.", msg)
+}
+func (v *ValidatorValues) In(values []string, msg ...string) *ValidatorValues {
+	if len(values) == 0 {
+		return v
+	}
+	return v.check(func(x string) bool {
+		for _, y := range values {
+			if x == y {
+				return true
+			}
+		}
+		return false
+	}, " is bad.", msg)
+}
+func (v *ValidatorValues) InInts(values []int, msg ...string) *ValidatorValues {
+	if len(values) == 0 {
+		return v
+	}
+	return v.check(func(x string) bool {
+		y, err := strconv.Atoi(x)
+		if err != nil {
+			return false
+		}
+		for _, z := range values {
+			if y == z {
+				return true
+			}
+		}
+		return false
+	}, " is bad.", msg)
+}
+func (v *ValidatorValues) IsUrl(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsURL, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsEmail(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsEmail, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsIP(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsIP, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsASCII(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsASCII, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsAlpha(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsAlpha, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsAlphanumeric(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsAlphanumeric, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsFilePath(msg ...string) *ValidatorValues {
+	return v.check(func(x string) bool {
+		ok, _ := govalidator.IsFilePath(x)
+		return ok
+	}, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsJSON(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsJSON, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsNumeric(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsNumeric, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsTime(format string, msg ...string) *ValidatorValues {
+	return v.check(func(x string) bool { return govalidator.IsTime(x, format) }, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsLowerCase(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsLowerCase, " is bad format.", msg)
+}
+func (v *ValidatorValues) IsUpperCase(msg ...string) *ValidatorValues {
+	return v.check(govalidator.IsUpperCase, " is bad format.", msg)
+}
+
+//Trim trim every value
+func (v *ValidatorValues) Trim() *ValidatorValues {
+	if v.goon {
+		v.own()
+		for i, x := range v.values {
+			v.values[i] = strings.TrimSpace(x)
+		}
+	}
+	return v
+}
+
+//Compact remove empty values
+func (v *ValidatorValues) Compact() *ValidatorValues {
+	if v.goon {
+		r := make([]string, 0, len(v.values))
+		for _, x := range v.values {
+			if "" != x {
+				r = append(r, x)
+			}
+		}
+		v.values = r
+	}
+	return v
+}
+
+//Unique values should not repeat
+func (v *ValidatorValues) Unique(msg ...string) *ValidatorValues {
+	if !v.goon {
+		return v
+	}
+	seen := make(map[string]bool, len(v.values))
+	failed := false
+	for i, x := range v.values {
+		if seen[x] {
+			v.ctx.AddParamError(v.keyAt(i), v.format(v.keyAt(i)+" is repeated.", msg))
+			failed = true
+		}
+		seen[x] = true
+	}
+	if failed {
+		v.goon = false
+	}
+	return v
+}
+
+//Each check every value with a scalar validator, eg: v.Each(func(e *Validator){e.Trim().IsEmail()})
+func (v *ValidatorValues) Each(fn func(e *Validator)) *ValidatorValues {
+	if !v.goon {
+		return v
+	}
+	v.own()
+	for i, x := range v.values {
+		e := NewValidator(v.ctx, v.keyAt(i), x, true)
+		fn(e)
+		if e.goon {
+			v.values[i] = e.value
+		} else if _, ok := v.ctx.ParamErrors()[e.key]; ok {
+			v.goon = false
+		}
+	}
+	return v
+}
+func (v *ValidatorValues) Present() bool {
+	return v.exists
+}
 
 func (v *ValidatorValues) Strings(dv []string, msg ...string) []string {
 	if v.goon {
@@ -533,7 +726,22 @@ func (v *ValidatorValues) Ints(dv []int, msg ...string) []int {
 		for i, x := range v.values {
 			r[i], err = strconv.Atoi(x)
 			if err != nil {
-				v.addError(v.format(v.key+" format error.", msg))
+				v.addErrorAt(i, v.format(v.keyAt(i)+" format error.", msg))
+				return dv
+			}
+		}
+		return r
+	}
+	return dv
+}
+func (v *ValidatorValues) Int64s(dv []int64, msg ...string) []int64 {
+	if v.goon {
+		r := make([]int64, len(v.values))
+		var err error
+		for i, x := range v.values {
+			r[i], err = strconv.ParseInt(x, 10, 64)
+			if err != nil {
+				v.addErrorAt(i, v.format(v.keyAt(i)+" format error.", msg))
 				return dv
 			}
 		}
@@ -548,7 +756,7 @@ func (v *ValidatorValues) Floats(dv []float64, msg ...string) []float64 {
 		for i, x := range v.values {
 			r[i], err = strconv.ParseFloat(x, 64)
 			if err != nil {
-				v.addError(v.format(v.key+" format error.", msg))
+				v.addErrorAt(i, v.format(v.keyAt(i)+" format error.", msg))
 				return dv
 			}
 		}
@@ -563,7 +771,22 @@ func (v *ValidatorValues) Float32(dv []float32, msg ...string) []float32 {
 			x1, err := strconv.ParseFloat(x, 32)
 			r[i] = float32(x1)
 			if err != nil {
-				v.addError(v.format(v.key+" format error.", msg))
+				v.addErrorAt(i, v.format(v.keyAt(i)+" format error.", msg))
+				return dv
+			}
+		}
+		return r
+	}
+	return dv
+}
+func (v *ValidatorValues) Bools(dv []bool, msg ...string) []bool {
+	if v.goon {
+		r := make([]bool, len(v.values))
+		var err error
+		for i, x := range v.values {
+			r[i], err = strconv.ParseBool(x)
+			if err != nil {
+				v.addErrorAt(i, v.format(v.keyAt(i)+" format error.", msg))
 				return dv
 			}
 		}
@@ -571,6 +794,27 @@ func (v *ValidatorValues) Float32(dv []float32, msg ...string) []float32 {
 	}
 	return dv
 }
+func (v *ValidatorValues) DateFormats(format string, dv []time.Time, msg ...string) []time.Time {
+	if v.goon {
+		r := make([]time.Time, len(v.values))
+		var err error
+		for i, x := range v.values {
+			r[i], err = ParseTimeLocal(format, x)
+			if err != nil {
+				v.addErrorAt(i, v.format(v.keyAt(i)+" format error.", msg))
+				return dv
+			}
+		}
+		return r
+	}
+	return dv
+}
+func (v *ValidatorValues) Dates(dv []time.Time, msg ...string) []time.Time {
+	return v.DateFormats("2006-01-02", dv, msg...)
+}
+func (v *ValidatorValues) DateTimes(dv []time.Time, msg ...string) []time.Time {
+	return v.DateFormats("2006-01-02 15:04:05", dv, msg...)
+}
 
 type ValidatorFile struct {
 	ctx *Context
@@ -581,6 +825,15 @@ type ValidatorFile struct {
 	exists bool
 	goon   bool
 	err    error
+	//contentType sniffed content type
+	contentType string
+	//imageConfig,imageFormat decoded image header
+	imageConfig image.Config
+	imageFormat string
+	//contentHash hex sha256 of content
+	contentHash string
+	//scanned file has been scanned by FileScanner
+	scanned bool
 	// isEmpty bool
 	// errors map[string]string
 }
@@ -633,10 +886,20 @@ func (v *ValidatorFile) Len(min, max int64, msg ...string) *ValidatorFile {
 	return v
 }
 
+func (v *ValidatorFile) Ensure(assertion, shouldBail bool, msg ...string) *ValidatorFile {
+	if shouldBail {
+		v.goon = false
+	}
+	if v.goon && !assertion {
+		v.addError(v.format(v.key+" failed an assertion.", msg))
+	}
+	return v
+}
+
 //ExtIn exts {"jpg","png"}
 func (v *ValidatorFile) ExtIn(exts []string, msg ...string) *ValidatorFile {
 	if v.goon && len(exts) > 0 {
-		ext := filepath.Ext(v.header.Filename)
+		ext := strings.ToLower(filepath.Ext(v.header.Filename))
 		for _, x := range exts {
 			if ext == "."+x {
 				return v
@@ -647,35 +910,40 @@ func (v *ValidatorFile) ExtIn(exts []string, msg ...string) *ValidatorFile {
 	return v
 }
 
+//IsImage ext should be image's and content should be image of the same format
 func (v *ValidatorFile) IsImage(msg ...string) *ValidatorFile {
-	exts := []string{"jpg", "jpeg", "png", "gif", "bmp", "tiff", "tif"}
-	if v.goon {
-		ext := filepath.Ext(v.header.Filename)
-		for _, x := range exts {
-			if ext == "."+x {
-				return v
-			}
-		}
-		v.addError(v.format(v.key+" is bad file type.", msg))
+	exts := []string{"jpg", "jpeg", "png", "gif", "bmp", "tiff", "tif", "webp"}
+	v.ExtIn(exts, msg...)
+	v.ContentTypeIn([]string{"image/*"}, msg...)
+	return v.ExtMatchContent(msg...)
+}
+
+//Close close the uploaded file, it can not be read any more
+func (v *ValidatorFile) Close() {
+	if v.file == nil {
+		return
 	}
-	return v
+	if err := v.file.Close(); err != nil {
+		log.Error().Func("Close").Err(err).Str("key", v.key).Msg(err.Error())
+	}
+	v.file = nil
 }
 
+//Copy copy file to dstFile atomically and close the file, the file should pass DefaultFileScanner first
 func (v *ValidatorFile) Copy(dstFile string) {
-	// src, err := v.file.Open()
-	// if err != nil {
-	// 	v.addError(v.key + ":" + err.Error())
-	// 	return
-	// }
-	defer v.file.Close()
-	dst, err := os.OpenFile(dstFile, os.O_CREATE, 0644)
-	if err != nil {
-		v.addError(v.key + ":" + err.Error())
+	if v.file == nil {
 		return
 	}
-	defer dst.Close()
-	_, err = io.Copy(dst, v.file)
+	defer v.Close()
+	if DefaultFileScanner != nil && !v.scanDefault() {
+		return
+	}
+	err := writeFileAtomic(dstFile, v.file)
+	if _, serr := v.file.Seek(0, io.SeekStart); err == nil {
+		err = serr
+	}
 	if err != nil {
+		log.Error().Func("Copy").Err(err).Stack().Str("key", v.key).Str("dstFile", dstFile).Msg(err.Error())
 		v.addError(v.key + ":" + err.Error())
 		return
 	}
@@ -689,7 +957,11 @@ type UploadFile struct {
 	Size   int64
 }
 
+//File get upload file, copy it to dstFile if dstFile is not empty and no error occurred, the file is closed after copying
 func (v *ValidatorFile) File(dstFile string) UploadFile {
+	if dstFile != "" && v.goon {
+		v.Copy(dstFile)
+	}
 	var r UploadFile
 	r.Header = v.header
 	r.File = v.file
@@ -701,9 +973,3 @@ func (v *ValidatorFile) File(dstFile string) UploadFile {
 	return r
 
 }
-
-// type SaveFileResult struct {
-// 	Url    string
-// 	Header *multipart.FileHeader
-// 	Error  error
-// }
@@ -0,0 +1,110 @@
+package irisx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+)
+
+func newTestContext(req *http.Request) (*irisx.Context, *httptest.ResponseRecorder) {
+	app := iris.New()
+	w := httptest.NewRecorder()
+	c := &irisx.Context{Context: context.NewContext(app)}
+	c.BeginRequest(w, req)
+	return c, w
+}
+
+func newTestApp() *iris.Application {
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.ContextPool.Attach(func() context.Context {
+		return &irisx.Context{Context: context.NewContext(app)}
+	})
+	return app
+}
+
+func serveTestApp(app *iris.Application) *httptest.Server {
+	if err := app.Build(); err != nil {
+		panic(err)
+	}
+	return httptest.NewServer(app)
+}
+
+func newFormContext(form url.Values) *irisx.Context {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c, _ := newTestContext(req)
+	return c
+}
+
+//go test -run TestValidatorValues -v
+func TestValidatorValues(t *testing.T) {
+	c := newFormContext(url.Values{"emails": {" a@b.com ", "bad", "c@d.com", "c@d.com"}})
+	c.CheckBodyValues("emails").NotEmpty().Trim().IsEmail()
+	errs := c.ParamErrors()
+	if len(errs) != 1 || errs["emails[1]"] == "" {
+		t.Fatal("expect emails[1] error, got:", errs)
+	}
+
+	c = newFormContext(url.Values{"tags": {"a", "b", "a"}})
+	tags := c.CheckBodyValues("tags").Unique().Strings(nil)
+	if tags != nil || c.ParamErrors()["tags[2]"] == "" {
+		t.Fatal("expect tags[2] repeated, got:", c.ParamErrors())
+	}
+
+	c = newFormContext(url.Values{"ids": {"1", "2", "x"}})
+	ids := c.CheckBodyValues("ids").Int64s(nil)
+	if ids != nil || c.ParamErrors()["ids[2]"] == "" {
+		t.Fatal("expect ids[2] format error, got:", c.ParamErrors())
+	}
+
+	c = newFormContext(url.Values{"names": {" tom ", "jerry"}})
+	names := c.CheckBodyValues("names").Each(func(e *irisx.Validator) {
+		e.Trim().Len(1, 5)
+	}).Strings(nil)
+	if len(c.ParamErrors()) != 0 || names[0] != "tom" {
+		t.Fatal("expect trimmed names, got:", names, c.ParamErrors())
+	}
+
+	c = newFormContext(url.Values{"tags": {" a ", "", "b"}})
+	tags = c.CheckBodyValues("tags").Trim().Compact().Strings(nil)
+	if len(tags) != 2 || tags[0] != "a" || c.FormValues()["tags"][0] != " a " || c.FormValues()["tags"][1] != "" {
+		t.Fatal("form values should not be changed, got:", tags, c.FormValues()["tags"])
+	}
+
+	c = newFormContext(url.Values{"days": {"2020-01-02", "2020-13-01"}})
+	c.CheckBodyValues("days").Dates(nil)
+	if c.ParamErrors()["days[1]"] == "" {
+		t.Fatal("expect days[1] error, got:", c.ParamErrors())
+	}
+}
+
+//go test -run TestSanitize -v
+func TestSanitize(t *testing.T) {
+	c := newFormContext(url.Values{"bio": {`<b>hi</b><script>alert(1)</script>`}, "name": {"tom"}})
+	bio := c.CheckBody("bio").Sanitize(irisx.SanitizeStrict)
+	if bio.String() != "hi" || !bio.Sanitized() {
+		t.Fatal("strict policy should strip all markup, got:", bio.String())
+	}
+	name := c.CheckBody("name").Sanitize(irisx.SanitizeRichText)
+	if name.String() != "tom" || name.Sanitized() {
+		t.Fatal("plain text should not be changed, got:", name.String())
+	}
+	c = newFormContext(url.Values{"title": {"tom & jerry"}, "tags": {"<i>a</i>", "b"}})
+	if title := c.CheckBody("title").Sanitize(irisx.SanitizeStrict); title.Sanitized() {
+		t.Fatal("escaped plain text should not be reported as sanitized, got:", title.String())
+	}
+	tags := c.CheckBodyValues("tags").Sanitize(irisx.SanitizeStrict)
+	if !tags.Sanitized() || tags.Strings(nil)[0] != "a" || c.FormValues()["tags"][0] != "<i>a</i>" {
+		t.Fatal("form values should not be changed, got:", tags.Strings(nil), c.FormValues()["tags"])
+	}
+	if s := irisx.SanitizeString(irisx.SanitizeComment, `<a href="http://x.com">x</a><img src="a.png">`); s != `<a href="http://x.com" rel="nofollow noopener" target="_blank">x</a>` {
+		t.Fatal("comment policy should allow links only, got:", s)
+	}
+}
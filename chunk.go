@@ -0,0 +1,481 @@
+package irisx
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/kataras/iris/v12"
+)
+
+//chunk upload error states
+const (
+	ChunkStateBadRequest = http.StatusBadRequest
+	ChunkStateNotFound   = http.StatusNotFound
+	ChunkStateConflict   = http.StatusConflict
+	ChunkStateTooLarge   = http.StatusRequestEntityTooLarge
+	ChunkStateServer     = http.StatusInternalServerError
+)
+
+//HeaderUploadOffset offset of chunk, can also be sent by query param "offset"
+const HeaderUploadOffset = "Upload-Offset"
+
+var ErrChunkUploadNotFound = errors.New("chunk upload not found")
+
+var chunkIdReg = regexp.MustCompile("^[0-9a-f]{32}$")
+
+//ChunkUploadState state of a resumable upload
+type ChunkUploadState struct {
+	Id       string `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Offset   int64  `json:"offset"`
+	//Checksum lower case hex sha256 of the whole file, optional
+	Checksum  string `json:"checksum,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+//ChunkStore store state of resumable uploads
+type ChunkStore interface {
+	Save(state *ChunkUploadState) error
+	//Get return ErrChunkUploadNotFound if id not exists
+	Get(id string) (*ChunkUploadState, error)
+	Remove(id string) error
+}
+
+//MemoryChunkStore keep states in memory, only for single node
+type MemoryChunkStore struct {
+	lock   sync.RWMutex
+	states map[string]ChunkUploadState
+}
+
+func NewMemoryChunkStore() *MemoryChunkStore {
+	return &MemoryChunkStore{states: make(map[string]ChunkUploadState)}
+}
+func (s *MemoryChunkStore) Save(state *ChunkUploadState) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.states[state.Id] = *state
+	return nil
+}
+func (s *MemoryChunkStore) Get(id string) (*ChunkUploadState, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	state, ok := s.states[id]
+	if !ok {
+		return nil, ErrChunkUploadNotFound
+	}
+	return &state, nil
+}
+func (s *MemoryChunkStore) Remove(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.states, id)
+	return nil
+}
+
+//RedisChunkStore keep states in redis, states expire after Expire
+type RedisChunkStore struct {
+	Client redis.Cmdable
+	Prefix string
+	Expire time.Duration
+}
+
+func NewRedisChunkStore(client redis.Cmdable, prefix string, expire time.Duration) *RedisChunkStore {
+	return &RedisChunkStore{Client: client, Prefix: prefix, Expire: expire}
+}
+func (s *RedisChunkStore) Save(state *ChunkUploadState) error {
+	bs, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(s.Prefix+state.Id, bs, s.Expire).Err()
+}
+func (s *RedisChunkStore) Get(id string) (*ChunkUploadState, error) {
+	bs, err := s.Client.Get(s.Prefix + id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrChunkUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state ChunkUploadState
+	err = json.Unmarshal(bs, &state)
+	return &state, err
+}
+func (s *RedisChunkStore) Remove(id string) error {
+	return s.Client.Del(s.Prefix + id).Err()
+}
+
+type ChunkUploadOptions struct {
+	//MaxSize max size of the whole file, <= 0 means no limit
+	MaxSize int64
+	//MaxChunkSize max size of a chunk, default 8M
+	MaxChunkSize int64
+	//TempDir dir of unfinished uploads, default os.TempDir()/irisx-chunks
+	TempDir string
+	//Store state store, default MemoryChunkStore
+	Store ChunkStore
+	//KeyFunc storage key of finished file, default DateKey("")
+	KeyFunc KeyFunc
+	//Field key of param errors, default "file"
+	Field string
+	//Validate rules of the finished file, eg: func(v *ValidatorFile){v.IsImage()}
+	Validate func(v *ValidatorFile)
+	//OnComplete respond the saved file, default ctx.Ok(result)
+	OnComplete func(ctx *Context, r SaveFileResult)
+	//Expire unfinished uploads inactive for Expire are removed, default 24h
+	Expire time.Duration
+}
+
+type chunkLock struct {
+	sync.Mutex
+	refs int
+}
+
+type chunkUploader struct {
+	storage Storage
+	opts    ChunkUploadOptions
+	//locks locks of uploads in use, removed when released by all requests
+	locksLock sync.Mutex
+	locks     map[string]*chunkLock
+	lastSweep time.Time
+}
+
+//ChunkUpload register a simple resumable upload protocol on path:
+//  POST   path        create upload with form params filename,size,checksum(hex sha256, optional), respond {id,offset}
+//  HEAD   path/{id}   respond Upload-Offset header
+//  GET    path/{id}   respond upload state
+//  PATCH  path/{id}   append body at Upload-Offset header, the file is validated and saved when the last chunk received
+//  DELETE path/{id}   abort upload
+func ChunkUpload(party iris.Party, path string, storage Storage, opts ChunkUploadOptions) {
+	if opts.MaxChunkSize <= 0 {
+		opts.MaxChunkSize = 8 << 20
+	}
+	if opts.TempDir == "" {
+		opts.TempDir = filepath.Join(os.TempDir(), "irisx-chunks")
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryChunkStore()
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = DateKey("")
+	}
+	if opts.Field == "" {
+		opts.Field = "file"
+	}
+	if opts.Expire <= 0 {
+		opts.Expire = 24 * time.Hour
+	}
+	if opts.OnComplete == nil {
+		opts.OnComplete = func(ctx *Context, r SaveFileResult) {
+			ctx.Ok(r)
+		}
+	}
+	u := &chunkUploader{storage: storage, opts: opts, locks: make(map[string]*chunkLock)}
+	party.Post(path, func(ctx iris.Context) { u.create(ctx.(*Context)) })
+	party.Head(path+"/{id:string}", func(ctx iris.Context) { u.head(ctx.(*Context)) })
+	party.Get(path+"/{id:string}", func(ctx iris.Context) { u.get(ctx.(*Context)) })
+	party.Patch(path+"/{id:string}", func(ctx iris.Context) { u.patch(ctx.(*Context)) })
+	party.Delete(path+"/{id:string}", func(ctx iris.Context) { u.remove(ctx.(*Context)) })
+}
+
+func (u *chunkUploader) tempFile(id string) string {
+	return filepath.Join(u.opts.TempDir, id)
+}
+//lock lock upload of id, id should be validated
+func (u *chunkUploader) lock(id string) func() {
+	u.locksLock.Lock()
+	l, ok := u.locks[id]
+	if !ok {
+		l = &chunkLock{}
+		u.locks[id] = l
+	}
+	l.refs++
+	u.locksLock.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		u.locksLock.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(u.locks, id)
+		}
+		u.locksLock.Unlock()
+	}
+}
+
+//sweep remove unfinished uploads inactive for Expire, at most once every Expire/4
+func (u *chunkUploader) sweep() {
+	u.locksLock.Lock()
+	now := time.Now()
+	if now.Sub(u.lastSweep) < u.opts.Expire/4 {
+		u.locksLock.Unlock()
+		return
+	}
+	u.lastSweep = now
+	u.locksLock.Unlock()
+	infos, err := ioutil.ReadDir(u.opts.TempDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Func("ChunkUpload").Err(err).Str("dir", u.opts.TempDir).Msg(err.Error())
+		}
+		return
+	}
+	for _, info := range infos {
+		id := info.Name()
+		if !chunkIdReg.MatchString(id) || now.Sub(info.ModTime()) < u.opts.Expire {
+			continue
+		}
+		unlock := u.lock(id)
+		//may be resumed before locked
+		if info, err := os.Stat(u.tempFile(id)); err == nil && now.Sub(info.ModTime()) >= u.opts.Expire {
+			os.Remove(u.tempFile(id))
+			if err := u.opts.Store.Remove(id); err != nil {
+				log.Error().Func("ChunkUpload").Err(err).Str("id", id).Msg(err.Error())
+			}
+		}
+		unlock()
+	}
+}
+
+//respondErr respond Err envelope with the same http status
+func (u *chunkUploader) respondErr(ctx *Context, state int, data interface{}) {
+	ctx.StatusCode(state)
+	ctx.Err(state, data)
+}
+func (u *chunkUploader) fail(ctx *Context, state int, err error) {
+	log.Error().Func("ChunkUpload").Err(err).Stack().Str("path", ctx.Path()).Msg(err.Error())
+	u.respondErr(ctx, state, err.Error())
+}
+
+//id validated id param, respond not found if invalid
+func (u *chunkUploader) id(ctx *Context) (string, bool) {
+	id := ctx.Params().Get("id")
+	if !chunkIdReg.MatchString(id) {
+		u.respondErr(ctx, ChunkStateNotFound, ErrChunkUploadNotFound.Error())
+		return "", false
+	}
+	return id, true
+}
+func (u *chunkUploader) state(ctx *Context, id string) *ChunkUploadState {
+	state, err := u.opts.Store.Get(id)
+	if err == ErrChunkUploadNotFound {
+		u.respondErr(ctx, ChunkStateNotFound, err.Error())
+		return nil
+	}
+	if err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return nil
+	}
+	return state
+}
+
+func (u *chunkUploader) create(ctx *Context) {
+	filename := ctx.CheckBody("filename").NotBlank().Len(1, 255).String()
+	size := ctx.CheckBody("size").NotEmpty().Int64(0)
+	checksum := ctx.CheckBody("checksum").Optional().Match("^[0-9a-fA-F]{64}$").String()
+	if len(ctx.ParamErrors()) == 0 && size <= 0 {
+		ctx.AddParamError("size", "size must great than 0")
+	}
+	if len(ctx.ParamErrors()) == 0 && u.opts.MaxSize > 0 && size > u.opts.MaxSize {
+		ctx.AddParamError("size", "size must equal or less than "+strconv.FormatInt(u.opts.MaxSize, 10))
+		u.respondErr(ctx, ChunkStateTooLarge, ctx.ParamErrors())
+		return
+	}
+	if len(ctx.ParamErrors()) != 0 {
+		u.respondErr(ctx, ChunkStateBadRequest, ctx.ParamErrors())
+		return
+	}
+	u.sweep()
+	state := &ChunkUploadState{Id: RandomName(16), Filename: filepath.Base(filename), Size: size, Checksum: strings.ToLower(checksum), CreatedAt: time.Now().Unix()}
+	if err := os.MkdirAll(u.opts.TempDir, 0755); err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	f, err := os.OpenFile(u.tempFile(state.Id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	f.Close()
+	if err := u.opts.Store.Save(state); err != nil {
+		os.Remove(u.tempFile(state.Id))
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	ctx.Ok(state)
+}
+
+func (u *chunkUploader) head(ctx *Context) {
+	id := ctx.Params().Get("id")
+	if !chunkIdReg.MatchString(id) {
+		ctx.StatusCode(http.StatusNotFound)
+		return
+	}
+	state, err := u.opts.Store.Get(id)
+	if err != nil {
+		ctx.StatusCode(http.StatusNotFound)
+		return
+	}
+	ctx.Header(HeaderUploadOffset, strconv.FormatInt(state.Offset, 10))
+	ctx.Header("Upload-Length", strconv.FormatInt(state.Size, 10))
+}
+
+func (u *chunkUploader) get(ctx *Context) {
+	id, ok := u.id(ctx)
+	if !ok {
+		return
+	}
+	if state := u.state(ctx, id); state != nil {
+		ctx.Ok(state)
+	}
+}
+
+func (u *chunkUploader) patch(ctx *Context) {
+	id, ok := u.id(ctx)
+	if !ok {
+		return
+	}
+	unlock := u.lock(id)
+	defer unlock()
+	state := u.state(ctx, id)
+	if state == nil {
+		return
+	}
+	offsetStr := ctx.GetHeader(HeaderUploadOffset)
+	if offsetStr == "" {
+		offsetStr = ctx.URLParam("offset")
+	}
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil {
+		ctx.AddParamError("offset", "offset is bad format.")
+		u.respondErr(ctx, ChunkStateBadRequest, ctx.ParamErrors())
+		return
+	}
+	if offset != state.Offset {
+		u.respondErr(ctx, ChunkStateConflict, state)
+		return
+	}
+	f, err := os.OpenFile(u.tempFile(state.Id), os.O_WRONLY, 0600)
+	if err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	limit := state.Size - offset
+	if limit > u.opts.MaxChunkSize {
+		limit = u.opts.MaxChunkSize
+	}
+	n, err := io.Copy(f, io.LimitReader(ctx.Request().Body, limit+1))
+	if n > limit {
+		//drop the whole chunk
+		err = f.Truncate(offset)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			u.fail(ctx, ChunkStateServer, err)
+			return
+		}
+		u.respondErr(ctx, ChunkStateTooLarge, state)
+		return
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		//keep the received bytes, client can resume from the new offset
+		log.Error().Func("ChunkUpload").Err(err).Str("id", state.Id).Int64("offset", offset).Msg(err.Error())
+	}
+	state.Offset += n
+	if serr := u.opts.Store.Save(state); serr != nil {
+		u.fail(ctx, ChunkStateServer, serr)
+		return
+	}
+	if err != nil {
+		u.respondErr(ctx, ChunkStateBadRequest, state)
+		return
+	}
+	if state.Offset < state.Size {
+		ctx.Header(HeaderUploadOffset, strconv.FormatInt(state.Offset, 10))
+		ctx.Ok(state)
+		return
+	}
+	u.complete(ctx, state)
+}
+
+//complete validate and save the finished file
+func (u *chunkUploader) complete(ctx *Context, state *ChunkUploadState) {
+	tmp := u.tempFile(state.Id)
+	defer func() {
+		os.Remove(tmp)
+		u.opts.Store.Remove(state.Id)
+	}()
+	f, err := os.Open(tmp)
+	if err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	//size of the received file, not the declared size
+	v := NewValidatorFile(ctx, u.opts.Field, f, &multipart.FileHeader{Filename: state.Filename, Size: info.Size()}, nil)
+	v.Len(state.Size, state.Size)
+	if state.Checksum != "" {
+		v.Ensure(v.Hash() == state.Checksum, false, u.opts.Field+"'s checksum not match.")
+	}
+	if u.opts.Validate != nil {
+		u.opts.Validate(v)
+	}
+	if len(ctx.ParamErrors()) != 0 {
+		u.respondErr(ctx, ChunkStateBadRequest, ctx.ParamErrors())
+		return
+	}
+	r := v.Save(u.storage, u.opts.KeyFunc)
+	if r.Error == ErrFileRejected {
+		u.respondErr(ctx, ChunkStateBadRequest, ctx.ParamErrors())
+		return
+	}
+	if r.Error != nil {
+		u.respondErr(ctx, ChunkStateServer, ctx.ParamErrors())
+		return
+	}
+	u.opts.OnComplete(ctx, r)
+}
+
+func (u *chunkUploader) remove(ctx *Context) {
+	id, ok := u.id(ctx)
+	if !ok {
+		return
+	}
+	unlock := u.lock(id)
+	defer unlock()
+	if state := u.state(ctx, id); state == nil {
+		return
+	}
+	os.Remove(u.tempFile(id))
+	if err := u.opts.Store.Remove(id); err != nil {
+		u.fail(ctx, ChunkStateServer, err)
+		return
+	}
+	ctx.Ok(nil)
+}
@@ -37,6 +37,8 @@ type Context struct {
 	context.Context
 	BeforeView      func(ctx *Context, tplFile string)
 	SessionProvider SessionProvider
+	//ProblemDetails Err responds RFC 7807 problem+json with real http status instead of the envelope
+	ProblemDetails bool
 }
 
 func (ctx *Context) Do(handlers context.Handlers) {
@@ -133,7 +135,7 @@ func (ctx *Context) View(filename string, optionalViewModel ...interface{}) erro
 }
 
 func (ctx *Context) Ok(data interface{}) {
-	ctx.JSON(errs.Err{State: 0, Data: data}.Result())
+	ctx.Respond(errs.Err{State: 0, Data: data}.Result())
 }
 
 // type Select2 struct {
@@ -145,7 +147,11 @@ func (ctx *Context) Ok(data interface{}) {
 // 	ctx.JSON(ctx.Error)
 // }
 func (ctx *Context) Err(status int, data interface{}) {
-	ctx.JSON(errs.Err{State: status, Data: data})
+	if ctx.ProblemDetails {
+		ctx.ErrProblem(status, data)
+		return
+	}
+	ctx.Respond(errs.Err{State: status, Data: data})
 }
 
 // func (ctx *Context) HasError() bool {
@@ -319,6 +325,13 @@ func (ctx *Context) CheckFile(field string) *ValidatorFile {
 	src, header, err := ctx.FormFile(field)
 	return NewValidatorFile(ctx, field, src, header, err)
 }
+func (ctx *Context) CheckFiles(field string) *ValidatorFiles {
+	err := ctx.Request().ParseMultipartForm(ctx.Application().ConfigurationReadOnly().GetPostMaxMemory())
+	if err != nil {
+		return NewValidatorFiles(ctx, field, nil, err)
+	}
+	return NewValidatorFiles(ctx, field, ctx.Request().MultipartForm.File[field], nil)
+}
 
 func (ctx *Context) AddScript(js string) string {
 	ctx.Values().Set(RequestKeyScripts, append(ctx.Scripts(), js))
@@ -0,0 +1,220 @@
+package irisx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	urlUtil "net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//CursorParam query param of cursor
+var CursorParam = "cursor"
+
+//CursorSecret key of cursor signature, should be shared by all nodes, default random per process
+var CursorSecret = []byte(RandomName(32))
+
+//CursorMaxAge cursors older than it are rejected, 0 means never expire
+var CursorMaxAge time.Duration
+
+var ErrBadCursor = errors.New("bad cursor")
+
+//Cursor position of keyset pagination: sort keys of the boundary row
+type Cursor struct {
+	//Keys json of sort keys
+	Keys []json.RawMessage `json:"k"`
+	//Backward rows before Keys, otherwise rows after Keys
+	Backward bool `json:"b,omitempty"`
+	//Time unix seconds when it was issued
+	Time int64 `json:"t"`
+}
+
+//NewCursor cursor of boundary row keys, keys should be json serializable
+func NewCursor(backward bool, keys ...interface{}) (*Cursor, error) {
+	c := &Cursor{Backward: backward, Time: time.Now().Unix(), Keys: make([]json.RawMessage, len(keys))}
+	for i, k := range keys {
+		bs, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		c.Keys[i] = bs
+	}
+	return c, nil
+}
+
+//Scan keys into dst pointers in order, eg: c.Scan(&createdAt, &id)
+func (c *Cursor) Scan(dst ...interface{}) error {
+	if len(dst) != len(c.Keys) {
+		return ErrBadCursor
+	}
+	for i, d := range dst {
+		if err := json.Unmarshal(c.Keys[i], d); err != nil {
+			return ErrBadCursor
+		}
+	}
+	return nil
+}
+
+func cursorSign(payload string) string {
+	mac := hmac.New(sha256.New, CursorSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+//Encode opaque signed token: base64(json).base64(hmac)
+func (c *Cursor) Encode() string {
+	bs, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(bs)
+	return payload + "." + cursorSign(payload)
+}
+
+//DecodeCursor verify signature and age of token
+func DecodeCursor(token string) (*Cursor, error) {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return nil, ErrBadCursor
+	}
+	payload := token[:dot]
+	if !hmac.Equal([]byte(token[dot+1:]), []byte(cursorSign(payload))) {
+		return nil, ErrBadCursor
+	}
+	bs, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	var c Cursor
+	if err = json.Unmarshal(bs, &c); err != nil || len(c.Keys) == 0 {
+		return nil, ErrBadCursor
+	}
+	if CursorMaxAge > 0 && time.Since(time.Unix(c.Time, 0)) > CursorMaxAge {
+		return nil, ErrBadCursor
+	}
+	return &c, nil
+}
+
+//Cursor cursor of request, nil for the first page. bad cursor is added to param errors of CursorParam.
+func (ctx *Context) Cursor() (*Cursor, error) {
+	token := ctx.URLParam(CursorParam)
+	if token == "" {
+		return nil, nil
+	}
+	c, err := DecodeCursor(token)
+	if err != nil {
+		log.Warn().Func("Cursor").Err(err).Str("cursor", token).Msg(err.Error())
+		ctx.AddParamError(CursorParam, "invalid cursor")
+		return nil, err
+	}
+	return c, nil
+}
+
+//CursorPage data of a page of keyset pagination
+type CursorPage struct {
+	Items interface{} `json:"items"`
+	//Next cursor of next page, empty if no more
+	Next string `json:"next,omitempty"`
+	//Prev cursor of previous page, empty on the first page
+	Prev string `json:"prev,omitempty"`
+}
+
+//NewCursorPage page of rows queried by cursor cur with limit+1 rows, the extra row tells if there are more.
+//backward cursors query rows in reversed order, they are reversed back here.
+//keys return sort keys of a row, rows must be a slice.
+func NewCursorPage(rows interface{}, limit int, cur *Cursor, keys func(row interface{}) []interface{}) CursorPage {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice || limit <= 0 {
+		return CursorPage{Items: []interface{}{}}
+	}
+	backward := cur != nil && cur.Backward
+	more := v.Len() > limit
+	if more {
+		v = v.Slice(0, limit)
+	}
+	n := v.Len()
+	if backward {
+		r := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			r.Index(i).Set(v.Index(n - 1 - i))
+		}
+		v = r
+	}
+	page := CursorPage{Items: v.Interface()}
+	if n == 0 {
+		return page
+	}
+	encode := func(backward bool, row interface{}) string {
+		c, err := NewCursor(backward, keys(row)...)
+		if err != nil {
+			log.Error().Func("NewCursorPage").Err(err).Stack().Msg(err.Error())
+			return ""
+		}
+		return c.Encode()
+	}
+	//forward: more rows after, came from a previous page if cur exists
+	//backward: more rows before, always came from a next page
+	if (!backward && more) || backward {
+		page.Next = encode(false, v.Index(n-1).Interface())
+	}
+	if (!backward && cur != nil) || (backward && more) {
+		page.Prev = encode(true, v.Index(0).Interface())
+	}
+	return page
+}
+
+//CursorUrl url with cursor param set, "#" if cursor is empty
+func CursorUrl(url, cursor string) string {
+	if cursor == "" {
+		return "#"
+	}
+	u, err := urlUtil.ParseRequestURI(url)
+	if err != nil {
+		return "#"
+	}
+	q := u.Query()
+	q.Set(CursorParam, cursor)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+//CursorPager prev/next links of cursor page, same markup as Page
+func CursorPager(ctx *Context, page CursorPage, classes string) string {
+	if nil == ctx {
+		return ""
+	}
+	url := ctx.Request().RequestURI
+	r := `<nav>
+  <ul class="` + classes + `">
+    <li`
+	if page.Prev == "" {
+		r += ` class="disabled"`
+	}
+	r += `>
+      <a href="` + html.EscapeString(CursorUrl(url, page.Prev)) + `" aria-label="Previous">
+        上一页
+      </a>
+    </li>
+    <li`
+	if page.Next == "" {
+		r += ` class="disabled"`
+	}
+	r += `>
+      <a href="` + html.EscapeString(CursorUrl(url, page.Next)) + `" aria-label="Next">
+        下一页
+      </a>
+    </li>
+  </ul>
+</nav>`
+	return r
+}
+
+//LoadMore "load more" link of cursor page, empty if no more. data-cursor can be used by ajax.
+func LoadMore(ctx *Context, page CursorPage, classes string) string {
+	if nil == ctx || page.Next == "" {
+		return ""
+	}
+	return `<a class="` + classes + `" href="` + html.EscapeString(CursorUrl(ctx.Request().RequestURI, page.Next)) + `" data-cursor="` + page.Next + `">加载更多</a>`
+}
@@ -0,0 +1,117 @@
+package irisx_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/kataras/iris/v12"
+)
+
+type cursorRow struct {
+	Id int `json:"id"`
+}
+
+//queryRows simulate keyset query of table with id 1...n: id > after asc, or id < before desc
+func queryRows(n int, cur *irisx.Cursor, limit int) []cursorRow {
+	var rows []cursorRow
+	if cur == nil {
+		for id := 1; id <= n && len(rows) < limit; id++ {
+			rows = append(rows, cursorRow{id})
+		}
+		return rows
+	}
+	var key int
+	cur.Scan(&key)
+	if cur.Backward {
+		for id := key - 1; id >= 1 && len(rows) < limit; id-- {
+			rows = append(rows, cursorRow{id})
+		}
+		return rows
+	}
+	for id := key + 1; id <= n && len(rows) < limit; id++ {
+		rows = append(rows, cursorRow{id})
+	}
+	return rows
+}
+
+//go test -run TestCursor -v
+func TestCursor(t *testing.T) {
+	c, _ := irisx.NewCursor(false, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), int64(1)<<60)
+	decoded, err := irisx.DecodeCursor(c.Encode())
+	var at time.Time
+	var id int64
+	if err != nil || decoded.Scan(&at, &id) != nil || id != 1<<60 || at.Year() != 2020 {
+		t.Fatal("cursor should round trip:", err, at, id)
+	}
+	token := c.Encode()
+	if _, err := irisx.DecodeCursor("x" + token); err != irisx.ErrBadCursor {
+		t.Fatal("tampered cursor should be rejected")
+	}
+	if err := decoded.Scan(&id); err != irisx.ErrBadCursor {
+		t.Fatal("keys count should be checked")
+	}
+
+	app := newTestApp()
+	app.Get("/rows", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		cur, err := ctx.Cursor()
+		if err != nil {
+			ctx.Err(1, ctx.ParamErrors())
+			return
+		}
+		rows := queryRows(7, cur, 3+1)
+		ctx.Ok(irisx.NewCursorPage(rows, 3, cur, func(row interface{}) []interface{} {
+			return []interface{}{row.(cursorRow).Id}
+		}))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+	type page struct {
+		Data struct {
+			Items []cursorRow
+			Next  string
+			Prev  string
+		}
+	}
+	fetch := func(cursor string) page {
+		_, bs := get(t, server.URL+"/rows?cursor="+url.QueryEscape(cursor), nil)
+		var p page
+		json.Unmarshal(bs, &p)
+		return p
+	}
+	ids := func(p page) string {
+		var r []string
+		for _, row := range p.Data.Items {
+			r = append(r, string(rune('0'+row.Id)))
+		}
+		return strings.Join(r, ",")
+	}
+	p1 := fetch("")
+	if ids(p1) != "1,2,3" || p1.Data.Prev != "" || p1.Data.Next == "" {
+		t.Fatal("first page not match:", p1)
+	}
+	p2 := fetch(p1.Data.Next)
+	if ids(p2) != "4,5,6" || p2.Data.Prev == "" || p2.Data.Next == "" {
+		t.Fatal("second page not match:", p2)
+	}
+	p3 := fetch(p2.Data.Next)
+	if ids(p3) != "7" || p3.Data.Next != "" || p3.Data.Prev == "" {
+		t.Fatal("last page not match:", p3)
+	}
+	back := fetch(p3.Data.Prev)
+	if ids(back) != "4,5,6" || back.Data.Prev == "" || back.Data.Next == "" {
+		t.Fatal("prev page not match:", back)
+	}
+	back = fetch(back.Data.Prev)
+	if ids(back) != "1,2,3" || back.Data.Prev != "" || back.Data.Next == "" {
+		t.Fatal("back to first page not match:", back)
+	}
+	_, bs := get(t, server.URL+"/rows?cursor=bad", nil)
+	if !strings.Contains(string(bs), `"cursor":"invalid cursor"`) {
+		t.Fatal("bad cursor should be param error:", string(bs))
+	}
+}
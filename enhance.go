@@ -172,6 +172,27 @@ func Enhance(app *view.HTMLEngine) {
 	app.AddFunc("page", func(ctx *Context, pageSize int, total int64, showTotal bool, cssClass string) template.HTML {
 		return template.HTML(Page(ctx, pageSize, total, showTotal, cssClass))
 	})
+	app.AddFunc("paginate", func(ctx *Context, pageSize int, total int64) (template.HTML, error) {
+		return DefaultPaginator.Render(ctx, pageSize, total)
+	})
+	app.AddFunc("paginateWith", func(p *Paginator, ctx *Context, pageSize int, total int64) (template.HTML, error) {
+		return p.Render(ctx, pageSize, total)
+	})
+	app.AddFunc("pageSize", func(ctx *Context, current int, props string) template.HTML {
+		return PageSizeSelector(ctx, current, props)
+	})
+	app.AddFunc("sortHeader", func(ctx *Context, q *ListQuery, field, label string) template.HTML {
+		return SortHeader(ctx, q, field, label)
+	})
+	app.AddFunc("filterChips", func(ctx *Context, q *ListQuery, cssClass string) template.HTML {
+		return FilterChips(ctx, q, cssClass)
+	})
+	app.AddFunc("cursorPage", func(ctx *Context, page CursorPage, cssClass string) template.HTML {
+		return template.HTML(CursorPager(ctx, page, cssClass))
+	})
+	app.AddFunc("loadMore", func(ctx *Context, page CursorPage, cssClass string) template.HTML {
+		return template.HTML(LoadMore(ctx, page, cssClass))
+	})
 	app.AddFunc("select", func(options map[int]string, defaultV interface{}, needEmptyOption bool, props string) template.HTML {
 		return selector(options, defaultV, needEmptyOption, props)
 	})
@@ -306,7 +327,7 @@ func Enhance(app *view.HTMLEngine) {
 		return Index(arr, value)
 	})
 	app.AddFunc("alphabet", func(i int) string {
-		return string('A' + i)
+		return string(rune('A' + i))
 	})
 	// app.AddFunc("Dict", func(module string) interface{} {
 	// 	return constant.Dicts[module]
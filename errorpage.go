@@ -0,0 +1,135 @@
+package irisx
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/RocksonZeta/wrap/errs"
+	"github.com/kataras/iris/v12"
+)
+
+//Development show error details and stack traces on error pages
+var Development = false
+
+//ErrorPageDir templates dir of error pages: {dir}/{status}.html, {dir}/default.html
+var ErrorPageDir = "errors"
+
+//ErrorPageData view data "Error" of error pages
+type ErrorPageData struct {
+	Status  int
+	State   int
+	Message string
+	Data    interface{}
+	//Detail error detail, only in development
+	Detail string
+	//Stack stack trace, only in development
+	Stack string
+}
+
+var builtinErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.Message}}</title>
+<style>body{font-family:sans-serif;margin:40px;color:#333}h1{font-weight:normal}pre{background:#f5f5f5;padding:12px;overflow:auto}</style>
+</head>
+<body>
+<h1>{{.Status}} {{.Message}}</h1>
+{{if .Detail}}<pre>{{.Detail}}</pre>{{end}}
+{{if .Stack}}<pre>{{.Stack}}</pre>{{end}}
+</body>
+</html>`))
+
+//errorPageTemplates fallback chain of mapping
+func errorPageTemplates(m ErrorMapping) []string {
+	var r []string
+	if m.Template != "" {
+		r = append(r, m.Template)
+	}
+	return append(r, path.Join(ErrorPageDir, strconv.Itoa(m.Status)+".html"), path.Join(ErrorPageDir, "default.html"))
+}
+
+//ErrorPage render error page of mapping by templates errors/{status}.html then errors/default.html, view data: Error
+func (ctx *Context) ErrorPage(m ErrorMapping, err error) {
+	var stack []byte
+	if Development && err != nil {
+		stack = debug.Stack()
+	}
+	ctx.errorPage(m, err, stack)
+}
+
+func (ctx *Context) errorPage(m ErrorMapping, err error, stack []byte) {
+	if m.Status == 0 {
+		m.Status = http.StatusInternalServerError
+	}
+	if m.Message == "" {
+		m.Message = http.StatusText(m.Status)
+	}
+	data := ErrorPageData{Status: m.Status, State: m.State, Message: m.Message, Data: m.Data}
+	if Development {
+		if err != nil {
+			data.Detail = err.Error()
+		}
+		data.Stack = string(stack)
+	}
+	ctx.ViewData("Error", data)
+	ctx.Title(strconv.Itoa(m.Status) + " " + m.Message)
+	tpls := errorPageTemplates(m)
+	ctx.ViewData("C", ctx)
+	//once for the chain, so Css and Js are not added repeatedly
+	if nil != ctx.BeforeView && len(tpls) > 0 {
+		ctx.BeforeView(ctx, tpls[0])
+	}
+	var verr error
+	for _, tpl := range tpls {
+		buf := &bytes.Buffer{}
+		if verr = ctx.renderTo(buf, tpl); verr == nil {
+			ctx.StatusCode(m.Status)
+			ctx.ContentType("text/html")
+			ctx.Write(buf.Bytes())
+			return
+		}
+	}
+	log.Warn().Func("errorPage").Err(verr).Interface("templates", tpls).Msg("error page templates failed, use builtin page")
+	ctx.StatusCode(m.Status)
+	buf := &bytes.Buffer{}
+	if terr := builtinErrorPage.Execute(buf, data); terr != nil {
+		log.Error().Func("errorPage").Err(terr).Stack().Msg(terr.Error())
+	}
+	ctx.ContentType("text/html")
+	ctx.Write(buf.Bytes())
+}
+
+//ErrorPages render error pages for html requests and Err envelope for others when iris fires error codes, eg: 404 of unknown routes
+func ErrorPages(app *iris.Application) {
+	app.OnAnyErrorCode(func(ictx iris.Context) {
+		//iris fires error codes with the inner context when ending requests
+		ctx, ok := ictx.(*Context)
+		if !ok {
+			ctx = &Context{Context: ictx}
+		}
+		status := ctx.GetStatusCode()
+		m := ErrorMapping{Status: status, State: status, Message: http.StatusText(status)}
+		if ctx.WantsHTML() {
+			ctx.errorPage(m, nil, nil)
+			return
+		}
+		if ctx.ProblemDetails {
+			ctx.ErrProblem(status, nil)
+			return
+		}
+		ctx.Respond(errs.Err{State: status, Message: m.Message})
+	})
+}
+
+//renderTo render view into w instead of response, so failed templates write nothing, BeforeView should be called by caller
+func (ctx *Context) renderTo(w io.Writer, filename string) error {
+	cfg := ctx.Application().ConfigurationReadOnly()
+	layout := ctx.Values().GetString(cfg.GetViewLayoutContextKey())
+	return ctx.Application().View(w, filename, layout, ctx.Values().Get(cfg.GetViewDataContextKey()))
+}
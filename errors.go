@@ -0,0 +1,169 @@
+package irisx
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/RocksonZeta/wrap/errs"
+)
+
+//common errors which can be mapped to responses by Fail
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrValidation   = errors.New("validation failed")
+)
+
+//ErrorMapping how an error responds
+type ErrorMapping struct {
+	//Status http status
+	Status int
+	//State state of the envelope, default Status
+	State int
+	//Template error page of html requests, default errors/{status}.html then errors/default.html
+	Template string
+	//Message shown to users, default err.Error() for 4xx and status text for 5xx
+	Message string
+	//Data of the envelope
+	Data interface{}
+}
+
+//ErrorMapper map err to response, return false if err is not handled by this mapper
+type ErrorMapper func(ctx *Context, err error) (ErrorMapping, bool)
+
+var errorMappers []ErrorMapper
+
+//RegisterErrorMapper register mapper at startup, mappers registered later take precedence
+func RegisterErrorMapper(mapper ErrorMapper) {
+	errorMappers = append(errorMappers, mapper)
+}
+
+//RegisterErrorSentinel map errors matching target by errors.Is, eg: RegisterErrorSentinel(sql.ErrNoRows,ErrorMapping{Status:404})
+func RegisterErrorSentinel(target error, mapping ErrorMapping) {
+	RegisterErrorMapper(func(ctx *Context, err error) (ErrorMapping, bool) {
+		return mapping, errors.Is(err, target)
+	})
+}
+
+//RegisterErrorType map errors of type by errors.As, eg: RegisterErrorType((*NotFoundError)(nil),ErrorMapping{Status:404})
+func RegisterErrorType(typ error, mapping ErrorMapping) {
+	t := reflect.TypeOf(typ)
+	RegisterErrorMapper(func(ctx *Context, err error) (ErrorMapping, bool) {
+		target := reflect.New(t)
+		return mapping, errors.As(err, target.Interface())
+	})
+}
+
+func init() {
+	RegisterErrorMapper(mapErrsErr)
+	RegisterErrorSentinel(ErrValidation, ErrorMapping{Status: http.StatusBadRequest})
+	RegisterErrorSentinel(ErrUnauthorized, ErrorMapping{Status: http.StatusUnauthorized})
+	RegisterErrorSentinel(ErrForbidden, ErrorMapping{Status: http.StatusForbidden})
+	RegisterErrorSentinel(ErrNotFound, ErrorMapping{Status: http.StatusNotFound})
+	RegisterErrorSentinel(ErrStorageKeyNotFound, ErrorMapping{Status: http.StatusNotFound})
+	RegisterErrorSentinel(ErrChunkUploadNotFound, ErrorMapping{Status: http.StatusNotFound})
+}
+
+//mapErrsErr errs.Err keeps its state, message and data, http status comes from problem types
+func mapErrsErr(ctx *Context, err error) (ErrorMapping, bool) {
+	var e *errs.Err
+	var ve errs.Err
+	if errors.As(err, &ve) {
+		e = &ve
+	} else if !errors.As(err, &e) {
+		return ErrorMapping{}, false
+	}
+	t := problemTypeOf(e.State)
+	if !e.UserError && t.Status < 500 {
+		if _, ok := problemTypes[e.State]; !ok {
+			t.Status = http.StatusInternalServerError
+		}
+	}
+	message := e.Message
+	//messages of internal errors may have sql or paths, they are logged rather than sent
+	if !e.UserError && t.Status >= 500 {
+		log.Error().Func("mapErrsErr").Int("state", e.State).Str("path", ctx.Path()).Msg(e.Message)
+		message = http.StatusText(t.Status)
+	}
+	return ErrorMapping{Status: t.Status, State: e.State, Message: message, Data: e.Data}, true
+}
+
+//MapError find mapping of err, default 500
+func MapError(ctx *Context, err error) ErrorMapping {
+	m := ErrorMapping{Status: http.StatusInternalServerError}
+	for i := len(errorMappers) - 1; i >= 0; i-- {
+		if r, ok := errorMappers[i](ctx, err); ok {
+			m = r
+			break
+		}
+	}
+	if m.Status == 0 {
+		m.Status = http.StatusInternalServerError
+	}
+	if m.State == 0 {
+		m.State = m.Status
+	}
+	if m.Message == "" {
+		if m.Status < 500 {
+			m.Message = err.Error()
+		} else {
+			m.Message = http.StatusText(m.Status)
+		}
+	}
+	if m.Data == nil && len(ctx.ParamErrors()) > 0 {
+		m.Data = ctx.ParamErrors()
+	}
+	return m
+}
+
+//WantsHTML request is from browser page rather than ajax or api clients
+func (ctx *Context) WantsHTML() bool {
+	if ctx.IsAjax() {
+		return false
+	}
+	for _, a := range parseAccept(ctx.GetHeader("Accept")) {
+		switch a.mediaType {
+		case "text/html", "application/xhtml+xml":
+			return true
+		case "*/*":
+			return false
+		}
+		for _, e := range responseEncoders {
+			if matchContentType(a.mediaType, e.contentType) {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+//Fail respond err by its mapping: error page for html requests, otherwise Err envelope or problem with real http status
+func (ctx *Context) Fail(err error) {
+	if err == nil {
+		return
+	}
+	m := MapError(ctx, err)
+	if m.Status >= 500 {
+		log.Error().Func("Fail").Err(err).Stack().Str("method", ctx.Method()).Str("path", ctx.Path()).Int("status", m.Status).Msg(err.Error())
+	} else {
+		log.Warn().Func("Fail").Err(err).Str("method", ctx.Method()).Str("path", ctx.Path()).Int("status", m.Status).Msg(err.Error())
+	}
+	if ctx.WantsHTML() {
+		ctx.ErrorPage(m, err)
+		return
+	}
+	ctx.respondMapping(m)
+}
+
+//respondMapping respond problem or Err envelope of mapping with real http status
+func (ctx *Context) respondMapping(m ErrorMapping) {
+	if ctx.ProblemDetails {
+		p := ctx.newProblem(m.State, ProblemType{Status: m.Status}, errs.Err{Message: m.Message, Data: m.Data})
+		ctx.writeProblem(p)
+		return
+	}
+	ctx.StatusCode(m.Status)
+	ctx.Respond(errs.Err{State: m.State, Message: m.Message, Data: m.Data})
+}
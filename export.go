@@ -0,0 +1,572 @@
+package irisx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CsvBOM write utf-8 BOM before csv of ExportCSV, or Excel will open utf-8 csv as local encoding, eg: GBK in Chinese locales
+var CsvBOM = true
+
+//ExportEscapeFormula prefix ' to csv text cells of ExportCSV and CsvStream starting with = + - @ tab or CR, or spreadsheets will run them as formulas.
+//xlsx cells are inline strings which are never evaluated, so they are not escaped
+var ExportEscapeFormula = true
+
+//ExportTimeFormat default format of time.Time cells
+var ExportTimeFormat = "2006-01-02 15:04:05"
+
+const ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+var ErrExportRows = errors.New("export rows should be a slice, Iterator or nil")
+
+//ExportColumn column of exported table
+type ExportColumn struct {
+	//Field name of struct field or key of map
+	Field string
+	//Header first row, default Field
+	Header string
+	//Format time layout for time.Time, fmt verb for others, eg: %.2f
+	Format string
+	//NumFmt number format of xlsx numeric cells, eg: #,##0.00
+	NumFmt string
+	//Width width of xlsx column in characters, 0 means default
+	Width float64
+}
+
+//ExportColumns columns of struct v by tags, v can be a struct, pointer to struct or slice of them.
+//
+//	type User struct {
+//		Id       int       `export:"ID,order=1"`
+//		Name     string    `export:"姓名,width=20"`
+//		Balance  float64   `export:"余额" numfmt:"#,##0.00"`
+//		Created  time.Time `export:"注册时间" exportfmt:"2006-01-02"`
+//		Password string    `export:"-"`
+//	}
+//
+//columns with order come first by order, others keep the declaration order.
+func ExportColumns(v interface{}) []ExportColumn {
+	return exportColumnsOf(reflect.TypeOf(v))
+}
+
+func exportColumnsOf(t reflect.Type) []ExportColumn {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	type ordered struct {
+		ExportColumn
+		order int
+	}
+	var cols []ordered
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("export")
+			if tag == "-" {
+				continue
+			}
+			if f.Anonymous && tag == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct && ft != timeType {
+					walk(ft)
+					continue
+				}
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			col := ordered{ExportColumn: ExportColumn{Field: f.Name, Header: f.Name, Format: f.Tag.Get("exportfmt"), NumFmt: f.Tag.Get("numfmt")}, order: 1 << 30}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				col.Header = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				kv := strings.SplitN(opt, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				switch strings.TrimSpace(kv[0]) {
+				case "order":
+					if n, err := strconv.Atoi(kv[1]); err == nil {
+						col.order = n
+					}
+				case "width":
+					if n, err := strconv.ParseFloat(kv[1], 64); err == nil {
+						col.Width = n
+					}
+				}
+			}
+			cols = append(cols, col)
+		}
+	}
+	walk(t)
+	sort.SliceStable(cols, func(i, j int) bool {
+		return cols[i].order < cols[j].order
+	})
+	r := make([]ExportColumn, len(cols))
+	for i, c := range cols {
+		r[i] = c.ExportColumn
+	}
+	return r
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+//exportIterator rows as Iterator, columns are parsed from the element type if empty
+func exportIterator(rows interface{}, columns []ExportColumn) (Iterator, []ExportColumn, error) {
+	var iter Iterator
+	switch r := rows.(type) {
+	case nil:
+		iter = SliceIterator([]interface{}{})
+	case Iterator:
+		iter = r
+	case func() (interface{}, bool, error):
+		iter = r
+	default:
+		v := reflect.ValueOf(rows)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, nil, ErrExportRows
+		}
+		if len(columns) == 0 {
+			columns = exportColumnsOf(v.Type())
+		}
+		return SliceIterator(rows), columns, nil
+	}
+	if len(columns) > 0 {
+		return iter, columns, nil
+	}
+	//peek the first row for columns
+	first, ok, err := iter()
+	if err != nil || !ok {
+		return iter, nil, err
+	}
+	columns = ExportColumns(first)
+	peeked := false
+	return func() (interface{}, bool, error) {
+		if !peeked {
+			peeked = true
+			return first, true, nil
+		}
+		return iter()
+	}, columns, nil
+}
+
+//exportCell value of column in row, nil if absent
+func exportCell(row reflect.Value, col ExportColumn) interface{} {
+	for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+		if row.IsNil() {
+			return nil
+		}
+		row = row.Elem()
+	}
+	var v reflect.Value
+	switch row.Kind() {
+	case reflect.Struct:
+		v = row.FieldByName(col.Field)
+	case reflect.Map:
+		v = row.MapIndex(reflect.ValueOf(col.Field))
+	}
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+//exportNumber numeric value of cell for xlsx, false if cell is not a number or has Format
+func exportNumber(cell interface{}, col ExportColumn) (float64, bool) {
+	if col.Format != "" {
+		return 0, false
+	}
+	return exportNumeric(cell)
+}
+
+func exportNumeric(cell interface{}) (float64, bool) {
+	v := reflect.ValueOf(cell)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+//exportString text of csv cell, formulas in text are escaped
+func exportString(cell interface{}, col ExportColumn) string {
+	s := exportText(cell, col)
+	if _, ok := exportNumeric(cell); !ok && ExportEscapeFormula {
+		return escapeFormula(s)
+	}
+	return s
+}
+
+//escapeFormula see https://owasp.org/www-community/attacks/CSV_Injection
+func escapeFormula(s string) string {
+	if s != "" && strings.IndexByte("=+-@\t\r", s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+//exportText text of cell
+func exportText(cell interface{}, col ExportColumn) string {
+	switch c := cell.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if c.IsZero() {
+			return ""
+		}
+		if col.Format != "" {
+			return c.Format(col.Format)
+		}
+		return c.Format(ExportTimeFormat)
+	}
+	if col.Format != "" {
+		return fmt.Sprintf(col.Format, cell)
+	}
+	switch c := cell.(type) {
+	case fmt.Stringer:
+		return c.String()
+	case error:
+		return c.Error()
+	}
+	return csvCell(cell)
+}
+
+//contentDisposition attachment with ascii fallback and RFC 5987 utf-8 filename
+func contentDisposition(filename string) string {
+	fallback := []byte(filename)
+	ascii := true
+	for i, b := range fallback {
+		if b >= 0x80 || b < 0x20 || b == '"' || b == '\\' {
+			fallback[i] = '_'
+			ascii = false
+		}
+	}
+	v := `attachment; filename="` + string(fallback) + `"`
+	if !ascii {
+		var sb strings.Builder
+		for _, b := range []byte(filename) {
+			if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteString(fmt.Sprintf("%%%02X", b))
+			}
+		}
+		v += "; filename*=UTF-8''" + sb.String()
+	}
+	return v
+}
+
+//ExportCSV stream rows as csv attachment, rows can be a slice of structs or maps, or an Iterator.
+//columns are parsed by ExportColumns if empty.
+func (ctx *Context) ExportCSV(filename string, rows interface{}, columns []ExportColumn) error {
+	iter, columns, err := exportIterator(rows, columns)
+	if err != nil {
+		log.Error().Func("ExportCSV").Err(err).Stack().Msg(err.Error())
+		return err
+	}
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Header
+		if col.Header == "" {
+			header[i] = col.Field
+		}
+	}
+	return ctx.csvStream(filename, header, CsvBOM, func() (interface{}, bool, error) {
+		row, ok, err := iter()
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+		v := reflect.ValueOf(row)
+		record := make(csvRow, len(columns))
+		for i, col := range columns {
+			record[i] = exportString(exportCell(v, col), col)
+		}
+		return record, true, nil
+	})
+}
+
+//ExportXLSX stream rows as xlsx attachment, see ExportCSV
+func (ctx *Context) ExportXLSX(filename string, rows interface{}, columns []ExportColumn) error {
+	iter, columns, err := exportIterator(rows, columns)
+	if err != nil {
+		log.Error().Func("ExportXLSX").Err(err).Stack().Msg(err.Error())
+		return err
+	}
+	//a truncated xlsx can not be opened, spool it to a temp file and respond only when it is complete
+	f, err := ioutil.TempFile("", "irisx-xlsx")
+	if err != nil {
+		ctx.Fail(err)
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	n := 0
+	x, err := NewXlsxWriter(f, "Sheet1", columns)
+	for err == nil {
+		row, ok, ierr := iter()
+		if ierr != nil {
+			err = ierr
+			break
+		}
+		if !ok {
+			err = x.Close()
+			break
+		}
+		if err = x.Write(row); err != nil {
+			break
+		}
+		n++
+		if ctx.Request().Context().Err() != nil {
+			err = ErrClientGone
+		}
+	}
+	var size int64
+	if err == nil {
+		size, err = f.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err == ErrClientGone {
+		ctx.logStreamErr("ExportXLSX", err, n)
+		return err
+	}
+	if err != nil {
+		//nothing has been written, respond the error with real status
+		ctx.Fail(err)
+		return err
+	}
+	//iris ContentType takes values with dot as file extensions
+	ctx.ResponseWriter().Header().Set("Content-Type", ContentTypeXLSX)
+	ctx.Header("Content-Disposition", contentDisposition(filename))
+	ctx.Header("Content-Length", strconv.FormatInt(size, 10))
+	if _, err = io.Copy(clientWriter{ctx}, f); err != nil {
+		ctx.logStreamErr("ExportXLSX", err, n)
+		return err
+	}
+	return nil
+}
+
+//XlsxWriter write rows to a single sheet xlsx without loading them in memory
+type XlsxWriter struct {
+	zw      *zip.Writer
+	sheet   io.Writer
+	columns []ExportColumn
+	//styles style index of columns
+	styles []int
+	row    int
+}
+
+//NewXlsxWriter write the workbook and header row, columns are required
+func NewXlsxWriter(w io.Writer, sheetName string, columns []ExportColumn) (*XlsxWriter, error) {
+	x := &XlsxWriter{zw: zip.NewWriter(w), columns: columns, styles: make([]int, len(columns))}
+	//cellXfs: 0 default, 1 bold header, 2... number formats
+	var numFmts, xfs strings.Builder
+	fmtIds := map[string]int{}
+	for i, col := range columns {
+		if col.NumFmt == "" {
+			continue
+		}
+		id, ok := fmtIds[col.NumFmt]
+		if !ok {
+			id = 164 + len(fmtIds)
+			fmtIds[col.NumFmt] = id
+			numFmts.WriteString(`<numFmt numFmtId="` + strconv.Itoa(id) + `" formatCode="` + xmlEscape(col.NumFmt) + `"/>`)
+			xfs.WriteString(`<xf numFmtId="` + strconv.Itoa(id) + `" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`)
+		}
+		x.styles[i] = 2 + id - 164
+	}
+	styles := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`
+	if len(fmtIds) > 0 {
+		styles += `<numFmts count="` + strconv.Itoa(len(fmtIds)) + `">` + numFmts.String() + `</numFmts>`
+	}
+	styles += `<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="` + strconv.Itoa(2+len(fmtIds)) + `"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` + xfs.String() + `</cellXfs>` +
+		`</styleSheet>`
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + xmlEscape(xlsxSheetName(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`},
+		{"xl/styles.xml", styles},
+	}
+	for _, f := range files {
+		fw, err := x.zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(fw, f.body); err != nil {
+			return nil, err
+		}
+	}
+	sheet, err := x.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x.sheet = sheet
+	head := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		//freeze the header row
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`
+	var cols strings.Builder
+	for i, col := range columns {
+		if col.Width > 0 {
+			n := strconv.Itoa(i + 1)
+			cols.WriteString(`<col min="` + n + `" max="` + n + `" width="` + strconv.FormatFloat(col.Width, 'f', -1, 64) + `" customWidth="1"/>`)
+		}
+	}
+	if cols.Len() > 0 {
+		head += `<cols>` + cols.String() + `</cols>`
+	}
+	head += `<sheetData>`
+	if _, err = io.WriteString(sheet, head); err != nil {
+		return nil, err
+	}
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = col.Header
+		if col.Header == "" {
+			header[i] = col.Field
+		}
+	}
+	if err = x.writeRow(header, 1); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+//xlsxSheetName excel limits sheet names to 31 chars without []:*?/\
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if rs := []rune(name); len(rs) > 31 {
+		name = string(rs[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+//xlsxColumn A, B, ... Z, AA, AB ...
+func xlsxColumn(i int) string {
+	var bs []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		bs = append([]byte{byte('A' + (i-1)%26)}, bs...)
+	}
+	return string(bs)
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+//writeRow cells are string or float64, style is used for strings
+func (x *XlsxWriter) writeRow(cells []interface{}, style int) error {
+	x.row++
+	r := strconv.Itoa(x.row)
+	var sb strings.Builder
+	sb.WriteString(`<row r="` + r + `">`)
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + r
+		switch c := cell.(type) {
+		case nil:
+		case float64:
+			sb.WriteString(`<c r="` + ref + `"`)
+			if x.styles[i] > 0 {
+				sb.WriteString(` s="` + strconv.Itoa(x.styles[i]) + `"`)
+			}
+			sb.WriteString(`><v>` + strconv.FormatFloat(c, 'g', -1, 64) + `</v></c>`)
+		case string:
+			if c == "" {
+				continue
+			}
+			sb.WriteString(`<c r="` + ref + `" t="inlineStr"`)
+			if style > 0 {
+				sb.WriteString(` s="` + strconv.Itoa(style) + `"`)
+			}
+			sb.WriteString(`><is><t xml:space="preserve">` + xmlEscape(c) + `</t></is></c>`)
+		}
+	}
+	sb.WriteString(`</row>`)
+	_, err := io.WriteString(x.sheet, sb.String())
+	return err
+}
+
+//Write a row of struct or map
+func (x *XlsxWriter) Write(row interface{}) error {
+	v := reflect.ValueOf(row)
+	cells := make([]interface{}, len(x.columns))
+	for i, col := range x.columns {
+		cell := exportCell(v, col)
+		if n, ok := exportNumber(cell, col); ok {
+			cells[i] = n
+		} else {
+			cells[i] = exportText(cell, col)
+		}
+	}
+	return x.writeRow(cells, 0)
+}
+
+//Close finish the sheet and the zip, the underlying writer is not closed
+func (x *XlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
@@ -0,0 +1,184 @@
+package irisx_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/kataras/iris/v12"
+)
+
+type exportBase struct {
+	Id int `export:"ID,order=1"`
+}
+
+type exportUser struct {
+	exportBase
+	Name     string    `export:"姓名,width=20"`
+	Balance  float64   `export:"余额" numfmt:"#,##0.00"`
+	Rate     float64   `export:"Rate" exportfmt:"%.1f%%"`
+	Created  time.Time `export:"注册时间" exportfmt:"2006-01-02"`
+	Note     *string
+	Password string `export:"-"`
+	secret   string
+}
+
+func exportUsers() []exportUser {
+	note := `a "quoted" <note>`
+	return []exportUser{
+		{exportBase: exportBase{Id: 1}, Name: "张三", Balance: 1234.5, Rate: 12.34, Created: time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC), Note: &note, Password: "x", secret: "y"},
+		{exportBase: exportBase{Id: 2}, Name: "tom", Balance: -1},
+	}
+}
+
+//go test -run TestExportColumns -v
+func TestExportColumns(t *testing.T) {
+	cols := irisx.ExportColumns([]*exportUser{})
+	var headers []string
+	for _, c := range cols {
+		headers = append(headers, c.Header)
+	}
+	if strings.Join(headers, ",") != "ID,姓名,余额,Rate,注册时间,Note" {
+		t.Fatal("headers not match:", headers)
+	}
+	if cols[1].Width != 20 || cols[2].NumFmt != "#,##0.00" || cols[4].Format != "2006-01-02" {
+		t.Fatal("options not match:", cols)
+	}
+}
+
+//go test -run TestExport -v
+func TestExport(t *testing.T) {
+	app := newTestApp()
+	app.Get("/csv", func(ictx iris.Context) {
+		ictx.(*irisx.Context).ExportCSV("用户.csv", exportUsers(), nil)
+	})
+	app.Get("/maps", func(ictx iris.Context) {
+		rows := []map[string]interface{}{{"id": 1, "name": "tom"}, {"id": 2}}
+		ictx.(*irisx.Context).ExportCSV("users.csv", rows, []irisx.ExportColumn{{Field: "name", Header: "Name"}, {Field: "id"}})
+	})
+	app.Get("/xlsx", func(ictx iris.Context) {
+		ictx.(*irisx.Context).ExportXLSX("users.xlsx", exportUsers(), nil)
+	})
+	formulas := []map[string]interface{}{{"name": "=HYPERLINK(\"http://x\")", "note": "@SUM(A1)", "n": -1}, {"name": "+1", "note": "-2"}}
+	formulaColumns := []irisx.ExportColumn{{Field: "name"}, {Field: "note"}, {Field: "n"}}
+	app.Get("/formula", func(ictx iris.Context) {
+		ictx.(*irisx.Context).ExportCSV("users.csv", formulas, formulaColumns)
+	})
+	app.Get("/formula.xlsx", func(ictx iris.Context) {
+		ictx.(*irisx.Context).ExportXLSX("users.xlsx", formulas, formulaColumns)
+	})
+	app.Get("/broken", func(ictx iris.Context) {
+		i := 0
+		ictx.(*irisx.Context).ExportXLSX("users.xlsx", func() (interface{}, bool, error) {
+			i++
+			if i > 300 {
+				return nil, false, errors.New("db is down")
+			}
+			return exportUsers()[0], true, nil
+		}, nil)
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	res, bs := get(t, server.URL+"/csv", nil)
+	if res.Header.Get("Content-Disposition") != `attachment; filename="______.csv"; filename*=UTF-8''%E7%94%A8%E6%88%B7.csv` {
+		t.Fatal("content disposition not match:", res.Header.Get("Content-Disposition"))
+	}
+	expected := "\uFEFFID,姓名,余额,Rate,注册时间,Note\n1,张三,1234.5,12.3%,2020-05-01,\"a \"\"quoted\"\" <note>\"\n2,tom,-1,0.0%,,\n"
+	if string(bs) != expected {
+		t.Fatal("csv not match:", string(bs))
+	}
+	_, bs = get(t, server.URL+"/maps", nil)
+	if string(bs) != "\uFEFFName,id\ntom,1\n,2\n" {
+		t.Fatal("csv of maps not match:", string(bs))
+	}
+
+	_, bs = get(t, server.URL+"/formula", nil)
+	if string(bs) != "\uFEFFname,note,n\n\"'=HYPERLINK(\"\"http://x\"\")\",'@SUM(A1),-1\n'+1,'-2,\n" {
+		t.Fatal("formulas should be escaped:", string(bs))
+	}
+	//inline strings of xlsx are never evaluated, keep them as is
+	_, bs = get(t, server.URL+"/formula.xlsx", nil)
+	zr, err := zip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, _ := f.Open()
+		body, _ := ioutil.ReadAll(r)
+		r.Close()
+		for _, s := range []string{
+			`<c r="A2" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;http://x&#34;)</t></is></c>`,
+			`<c r="B3" t="inlineStr"><is><t xml:space="preserve">-2</t></is></c>`,
+			`<c r="C2"><v>-1</v></c>`,
+		} {
+			if !strings.Contains(string(body), s) {
+				t.Fatal("xlsx should contain", s, "\n", string(body))
+			}
+		}
+	}
+	res, bs = get(t, server.URL+"/broken", nil)
+	if res.StatusCode != 500 || res.Header.Get("Content-Disposition") != "" || res.Header.Get("Content-Type") == irisx.ContentTypeXLSX {
+		t.Fatal("broken xlsx should not be sent:", res.StatusCode, res.Header)
+	}
+
+	res, bs = get(t, server.URL+"/xlsx", nil)
+	if res.Header.Get("Content-Length") != strconv.Itoa(len(bs)) {
+		t.Fatal("content length not match:", res.Header.Get("Content-Length"), len(bs))
+	}
+	if res.Header.Get("Content-Type") != irisx.ContentTypeXLSX {
+		t.Fatal("content type not match:", res.Header.Get("Content-Type"))
+	}
+	zr, err = zip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, _ := f.Open()
+		body, _ := ioutil.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(body)
+		//every part should be well formed
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := dec.Token(); err != nil {
+				if err.Error() != "EOF" {
+					t.Fatal(f.Name, "is not well formed:", err)
+				}
+				break
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Fatal("missing part:", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, s := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">ID</t></is></c>`,
+		`<c r="C2" s="2"><v>1234.5</v></c>`,
+		`<c r="D2" t="inlineStr"><is><t xml:space="preserve">12.3%</t></is></c>`,
+		`<c r="F2" t="inlineStr"><is><t xml:space="preserve">a &#34;quoted&#34; &lt;note&gt;</t></is></c>`,
+		`<c r="A3"><v>2</v></c>`,
+		`<col min="2" max="2" width="20" customWidth="1"/>`,
+	} {
+		if !strings.Contains(sheet, s) {
+			t.Fatal("sheet should contain", s, "\n", sheet)
+		}
+	}
+	if !strings.Contains(files["xl/styles.xml"], `<numFmt numFmtId="164" formatCode="#,##0.00"/>`) {
+		t.Fatal("number format not match:", files["xl/styles.xml"])
+	}
+}
@@ -0,0 +1,182 @@
+package irisx
+
+import (
+	"mime/multipart"
+	"strconv"
+)
+
+//ValidatorFiles validate all files of a field, errors of single file are keyed per index, eg: photos[1]
+type ValidatorFiles struct {
+	ctx    *Context
+	key    string
+	files  []*ValidatorFile
+	//opened files are opened lazily, so Count and TotalLen can reject requests with many parts before opening them
+	opened []bool
+	exists bool
+	goon   bool
+	err    error
+}
+
+func NewValidatorFiles(ctx *Context, key string, headers []*multipart.FileHeader, err error) *ValidatorFiles {
+	v := &ValidatorFiles{
+		ctx:    ctx,
+		key:    key,
+		exists: err == nil && len(headers) > 0,
+		goon:   true,
+		err:    err,
+	}
+	v.files = make([]*ValidatorFile, len(headers))
+	v.opened = make([]bool, len(headers))
+	for i, h := range headers {
+		v.files[i] = NewValidatorFile(ctx, v.keyAt(i), nil, h, nil)
+	}
+	return v
+}
+
+//open open the i-th file once
+func (v *ValidatorFiles) open(i int) {
+	if v.opened[i] {
+		return
+	}
+	v.opened[i] = true
+	f := v.files[i]
+	if f.file, f.err = f.header.Open(); f.err != nil {
+		f.exists = false
+	}
+}
+
+func (v *ValidatorFiles) addError(msg string) {
+	v.goon = false
+	v.ctx.AddParamError(v.key, msg)
+	v.Close()
+}
+func (v *ValidatorFiles) keyAt(i int) string {
+	return v.key + "[" + strconv.Itoa(i) + "]"
+}
+func (v *ValidatorFiles) hasError() bool {
+	return len(v.ctx.ParamErrors()) != 0
+}
+func (v *ValidatorFiles) format(defaultMsg string, msg []string) string {
+	if len(msg) > 0 {
+		return msg[0]
+	}
+	return defaultMsg
+}
+func (v *ValidatorFiles) Optional() *ValidatorFiles {
+	if !v.exists {
+		v.goon = false
+	}
+	return v
+}
+func (v *ValidatorFiles) NotEmpty(msg ...string) *ValidatorFiles {
+	if v.goon && len(v.files) == 0 {
+		v.addError(v.format(v.key+" can not be empty.", msg))
+	}
+	return v
+}
+
+//Count limit count of files, max <= 0 means no limit
+func (v *ValidatorFiles) Count(min, max int, msg ...string) *ValidatorFiles {
+	if v.goon {
+		if len(v.files) < min {
+			v.addError(v.format(v.key+"'s count must equal or great than "+strconv.Itoa(min), msg))
+			return v
+		}
+		if max > 0 && len(v.files) > max {
+			v.addError(v.format(v.key+"'s count must equal or less than "+strconv.Itoa(max), msg))
+			return v
+		}
+	}
+	return v
+}
+
+//TotalLen limit total size of files, max <= 0 means no limit
+func (v *ValidatorFiles) TotalLen(min, max int64, msg ...string) *ValidatorFiles {
+	if v.goon {
+		var size int64
+		for _, f := range v.files {
+			if f.header != nil {
+				size += f.header.Size
+			}
+		}
+		if size < min {
+			v.addError(v.format(v.key+"'s total length must equal or great than "+strconv.FormatInt(min, 10), msg))
+			return v
+		}
+		if max > 0 && size > max {
+			v.addError(v.format(v.key+"'s total length must equal or less than "+strconv.FormatInt(max, 10), msg))
+			return v
+		}
+	}
+	return v
+}
+
+//Each check every file, eg: v.Each(func(f *ValidatorFile){f.IsImage().Len(0,1<<20)})
+func (v *ValidatorFiles) Each(fn func(f *ValidatorFile)) *ValidatorFiles {
+	if !v.goon {
+		return v
+	}
+	for i, f := range v.files {
+		if f.goon {
+			v.open(i)
+			fn(f)
+		}
+		if !f.goon {
+			if _, ok := v.ctx.ParamErrors()[f.key]; ok {
+				v.goon = false
+			}
+		}
+	}
+	if !v.goon {
+		v.Close()
+	}
+	return v
+}
+func (v *ValidatorFiles) Len(min, max int64, msg ...string) *ValidatorFiles {
+	return v.Each(func(f *ValidatorFile) { f.Len(min, max, msg...) })
+}
+func (v *ValidatorFiles) ExtIn(exts []string, msg ...string) *ValidatorFiles {
+	return v.Each(func(f *ValidatorFile) { f.ExtIn(exts, msg...) })
+}
+func (v *ValidatorFiles) IsImage(msg ...string) *ValidatorFiles {
+	return v.Each(func(f *ValidatorFile) { f.IsImage(msg...) })
+}
+func (v *ValidatorFiles) ContentTypeIn(types []string, msg ...string) *ValidatorFiles {
+	return v.Each(func(f *ValidatorFile) { f.ContentTypeIn(types, msg...) })
+}
+func (v *ValidatorFiles) ImageSize(minW, minH, maxW, maxH int, msg ...string) *ValidatorFiles {
+	return v.Each(func(f *ValidatorFile) { f.ImageSize(minW, minH, maxW, maxH, msg...) })
+}
+
+//Files validators of every file, all files are opened
+func (v *ValidatorFiles) Files() []*ValidatorFile {
+	for i := range v.files {
+		v.open(i)
+	}
+	return v.files
+}
+func (v *ValidatorFiles) Present() bool {
+	return v.exists
+}
+
+//Close close all opened files, files are not opened after it. it is called by Save and on validation errors, defer it if files are not saved
+func (v *ValidatorFiles) Close() {
+	for i, f := range v.files {
+		v.opened[i] = true
+		f.Close()
+	}
+}
+
+//Save save all files to storage if no error occurred, files are closed after saving
+func (v *ValidatorFiles) Save(storage Storage, keyFunc KeyFunc) []SaveFileResult {
+	defer v.Close()
+	if !v.goon {
+		return nil
+	}
+	r := make([]SaveFileResult, len(v.files))
+	for i, f := range v.files {
+		v.open(i)
+		r[i] = f.Save(storage, keyFunc)
+	}
+	return r
+}
@@ -8,6 +8,11 @@ require (
 	github.com/asaskevich/govalidator v0.0.0-20200428143746-21a406dcc535
 	github.com/fatih/structs v1.1.0
 	github.com/go-redis/redis/v7 v7.2.0
+	github.com/golang/protobuf v1.3.2
+	github.com/gorilla/websocket v1.4.1
 	github.com/kataras/iris/v12 v12.1.8
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/microcosm-cc/bluemonday v1.0.2
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/image v0.0.0-20200119044424-58c23975cae1
 )
@@ -0,0 +1,122 @@
+package irisx
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"strconv"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+//decodeImageConfig decode only the image header, and rewind the file for later reading.
+func (v *ValidatorFile) decodeImageConfig() (image.Config, string, bool) {
+	if v.imageFormat != "" {
+		return v.imageConfig, v.imageFormat, true
+	}
+	if v.file == nil {
+		return v.imageConfig, "", false
+	}
+	config, format, err := image.DecodeConfig(v.file)
+	if _, serr := v.file.Seek(0, io.SeekStart); serr != nil {
+		log.Error().Func("decodeImageConfig").Err(serr).Stack().Str("key", v.key).Msg(serr.Error())
+		return config, "", false
+	}
+	if err != nil {
+		return config, "", false
+	}
+	v.imageConfig = config
+	v.imageFormat = format
+	return config, format, true
+}
+
+//ImageConfig width,height and format of image, ok is false if it is not a decodable image.
+func (v *ValidatorFile) ImageConfig() (width, height int, format string, ok bool) {
+	config, format, ok := v.decodeImageConfig()
+	return config.Width, config.Height, format, ok
+}
+
+//ImageFormatIn formats: {"png","jpeg","gif"}
+func (v *ValidatorFile) ImageFormatIn(formats []string, msg ...string) *ValidatorFile {
+	if v.goon {
+		_, format, ok := v.decodeImageConfig()
+		if ok {
+			if len(formats) == 0 {
+				return v
+			}
+			for _, x := range formats {
+				if x == format {
+					return v
+				}
+			}
+		}
+		v.addError(v.format(v.key+" is bad image format.", msg))
+	}
+	return v
+}
+
+//ImageSize limit width and height in pixels, max <= 0 means no limit
+func (v *ValidatorFile) ImageSize(minW, minH, maxW, maxH int, msg ...string) *ValidatorFile {
+	if v.goon {
+		config, _, ok := v.decodeImageConfig()
+		if !ok {
+			v.addError(v.format(v.key+" is bad image format.", msg))
+			return v
+		}
+		if config.Width < minW || config.Height < minH {
+			v.addError(v.format(v.key+"'s "+sizeLimit(minW, minH)+" must equal or great than "+sizeLimitValue(minW, minH), msg))
+			return v
+		}
+		if maxW > 0 && config.Width > maxW || maxH > 0 && config.Height > maxH {
+			v.addError(v.format(v.key+"'s "+sizeLimit(maxW, maxH)+" must equal or less than "+sizeLimitValue(maxW, maxH), msg))
+			return v
+		}
+	}
+	return v
+}
+
+//sizeLimit name of the limited dimensions, limit <= 0 is not set
+func sizeLimit(w, h int) string {
+	if w <= 0 {
+		return "height"
+	}
+	if h <= 0 {
+		return "width"
+	}
+	return "size"
+}
+func sizeLimitValue(w, h int) string {
+	if w <= 0 {
+		return strconv.Itoa(h)
+	}
+	if h <= 0 {
+		return strconv.Itoa(w)
+	}
+	return strconv.Itoa(w) + "x" + strconv.Itoa(h)
+}
+
+//AspectRatio width/height should be w/h, tolerance is the allowed relative deviation, eg: AspectRatio(16,9,0.01)
+func (v *ValidatorFile) AspectRatio(w, h int, tolerance float64, msg ...string) *ValidatorFile {
+	if v.goon {
+		if w <= 0 || h <= 0 {
+			v.addError(v.format(v.key+"'s aspect ratio "+strconv.Itoa(w)+":"+strconv.Itoa(h)+" should be positive", msg))
+			return v
+		}
+		config, _, ok := v.decodeImageConfig()
+		if !ok || config.Height == 0 {
+			v.addError(v.format(v.key+" is bad image format.", msg))
+			return v
+		}
+		expect := float64(w) / float64(h)
+		actual := float64(config.Width) / float64(config.Height)
+		if math.Abs(actual-expect)/expect > tolerance {
+			v.addError(v.format(v.key+"'s aspect ratio must be "+strconv.Itoa(w)+":"+strconv.Itoa(h), msg))
+		}
+	}
+	return v
+}
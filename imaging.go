@@ -0,0 +1,353 @@
+package irisx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+//resize modes
+const (
+	//ResizeFit scale the image to fit in the box, keep aspect ratio
+	ResizeFit = iota
+	//ResizeFill scale the image to fill the box and crop the center
+	ResizeFill
+)
+
+var ErrImageVariantNotFound = errors.New("image variant not found")
+var ErrImageTooLarge = errors.New("image too large")
+
+//MaxImagePixels images with more pixels are rejected before decoding, <= 0 means no limit
+var MaxImagePixels = 40000000
+
+//OriginalImageQuality jpeg quality of re-encoded original image
+var OriginalImageQuality = 92
+
+//ImageVariant a resized copy of image, Width or Height <= 0 means no limit, both <= 0 means re-encode only
+type ImageVariant struct {
+	Name   string
+	Width  int
+	Height int
+	Mode   int
+	//Format "jpeg" or "png", empty means jpeg for jpeg source and png for others
+	Format string
+	//Quality jpeg quality, default 85
+	Quality int
+}
+
+var imageVariants = map[string]ImageVariant{}
+
+//RegisterImageVariant register named variant at startup, eg: RegisterImageVariant(ImageVariant{Name:"thumb",Width:200,Height:200,Mode:ResizeFill})
+func RegisterImageVariant(variant ImageVariant) {
+	imageVariants[variant.Name] = variant
+}
+
+//DecodeImage decode image and fix jpeg exif orientation, images larger than MaxImagePixels are rejected
+func DecodeImage(r io.Reader) (image.Image, string, error) {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(bs))
+	if err != nil {
+		return nil, "", err
+	}
+	if MaxImagePixels > 0 && int64(config.Width)*int64(config.Height) > int64(MaxImagePixels) {
+		return nil, "", ErrImageTooLarge
+	}
+	img, format, err := image.Decode(bytes.NewReader(bs))
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(bs))
+	}
+	return img, format, nil
+}
+
+//ProcessImage resize and re-encode img, metadata is stripped. return encoded bytes and format.
+func ProcessImage(img image.Image, srcFormat string, variant ImageVariant) ([]byte, string, error) {
+	img = resizeImage(img, variant)
+	format := variant.Format
+	if format == "" {
+		format = "png"
+		if srcFormat == "jpeg" {
+			format = "jpeg"
+		}
+	}
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case "jpeg", "jpg":
+		format = "jpeg"
+		quality := variant.Quality
+		if quality <= 0 {
+			quality = 85
+		}
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(buf, img)
+	default:
+		err = errors.New("unsupported image format: " + format)
+	}
+	return buf.Bytes(), format, err
+}
+
+func resizeImage(img image.Image, variant ImageVariant) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 || variant.Width <= 0 && variant.Height <= 0 {
+		return img
+	}
+	sx, sy := scaleOf(variant.Width, w), scaleOf(variant.Height, h)
+	var scale float64
+	if variant.Mode == ResizeFill && variant.Width > 0 && variant.Height > 0 {
+		scale = sx
+		if sy > scale {
+			scale = sy
+		}
+	} else {
+		scale = sx
+		if sy < scale {
+			scale = sy
+		}
+	}
+	//never upscale
+	if scale > 1 {
+		scale = 1
+	}
+	dw, dh := round(float64(w)*scale), round(float64(h)*scale)
+	src := b
+	if variant.Mode == ResizeFill && variant.Width > 0 && variant.Height > 0 && (dw > variant.Width || dh > variant.Height) {
+		//crop the center of source which has the aspect ratio of the box
+		cw, ch := w, h
+		if dw > variant.Width {
+			cw = round(float64(variant.Width) / scale)
+			dw = variant.Width
+		}
+		if dh > variant.Height {
+			ch = round(float64(variant.Height) / scale)
+			dh = variant.Height
+		}
+		x0, y0 := b.Min.X+(w-cw)/2, b.Min.Y+(h-ch)/2
+		src = image.Rect(x0, y0, x0+cw, y0+ch)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+//scaleOf limit <= 0 means no limit
+func scaleOf(limit, size int) float64 {
+	if limit <= 0 {
+		return 1 << 20
+	}
+	return float64(limit) / float64(size)
+}
+func round(f float64) int {
+	r := int(f + 0.5)
+	if r < 1 {
+		return 1
+	}
+	return r
+}
+
+//jpegOrientation read exif orientation from jpeg bytes, 1 if not found
+func jpegOrientation(bs []byte) int {
+	if len(bs) < 4 || bs[0] != 0xFF || bs[1] != 0xD8 {
+		return 1
+	}
+	i := 2
+	for i+4 <= len(bs) {
+		if bs[i] != 0xFF {
+			return 1
+		}
+		marker := bs[i+1]
+		size := int(binary.BigEndian.Uint16(bs[i+2:]))
+		//start of scan, no more metadata
+		if marker == 0xDA || size < 2 || i+2+size > len(bs) {
+			return 1
+		}
+		seg := bs[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+//orient transform img to normal orientation
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	//orientation 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+//variantKey key of variant: a/b.jpg => a/b_thumb.png
+func variantKey(key, name, format string) string {
+	ext := path.Ext(key)
+	if format == "jpeg" {
+		format = "jpg"
+	}
+	return strings.TrimSuffix(key, ext) + "_" + name + "." + format
+}
+
+type SaveImageResult struct {
+	SaveFileResult
+	Variants map[string]SaveFileResult
+}
+
+//SaveImage save the re-encoded original and its named variants and close the file, metadata like exif and gps of the upload is stripped.
+//eg: v.IsImage().SaveImage(storage,irisx.HashKey("avatar"),"thumb","medium")
+func (v *ValidatorFile) SaveImage(storage Storage, keyFunc KeyFunc, variants ...string) SaveImageResult {
+	defer v.Close()
+	var r SaveImageResult
+	if !v.goon || v.file == nil {
+		r.Error = v.saveErr()
+		return r
+	}
+	if !v.scanDefault() {
+		r.Error = ErrFileRejected
+		return r
+	}
+	img, format, err := DecodeImage(v.file)
+	if _, serr := v.file.Seek(0, io.SeekStart); err == nil {
+		err = serr
+	}
+	if err != nil {
+		log.Error().Func("SaveImage").Err(err).Stack().Str("key", v.key).Msg(err.Error())
+		if err == ErrImageTooLarge {
+			v.addError(v.format(v.key+"'s pixels must equal or less than "+strconv.Itoa(MaxImagePixels), nil))
+		} else {
+			v.addError(v.format(v.key+" is bad image format.", nil))
+		}
+		r.Error = err
+		return r
+	}
+	bs, format, err := ProcessImage(img, format, ImageVariant{Quality: OriginalImageQuality})
+	if err == nil {
+		file := v.File("")
+		file.Ext = "." + format
+		if format == "jpeg" {
+			file.Ext = ".jpg"
+		}
+		file.Size = int64(len(bs))
+		r.Hash = sha256Hex(bs)
+		r.Key = keyFunc(file, r.Hash)
+		r.Size = file.Size
+		r.ContentType = "image/" + format
+		err = storage.Put(r.Key, bytes.NewReader(bs), r.Size, r.ContentType)
+	}
+	if err != nil {
+		log.Error().Func("SaveImage").Err(err).Stack().Str("key", v.key).Str("storageKey", r.Key).Msg(err.Error())
+		v.addError(v.key + ":" + err.Error())
+		r.Error = err
+		return r
+	}
+	r.Url = storage.Url(r.Key)
+	r.Variants = make(map[string]SaveFileResult, len(variants))
+	for _, name := range variants {
+		variant, ok := imageVariants[name]
+		var vr SaveFileResult
+		if !ok {
+			vr.Error = ErrImageVariantNotFound
+			log.Error().Func("SaveImage").Err(vr.Error).Str("variant", name).Msg(vr.Error.Error())
+			r.Variants[name] = vr
+			continue
+		}
+		bs, vformat, err := ProcessImage(img, format, variant)
+		if err == nil {
+			vr.Key = variantKey(r.Key, name, vformat)
+			vr.Size = int64(len(bs))
+			vr.ContentType = "image/" + vformat
+			vr.Hash = sha256Hex(bs)
+			err = storage.Put(vr.Key, bytes.NewReader(bs), vr.Size, vr.ContentType)
+		}
+		if err != nil {
+			log.Error().Func("SaveImage").Err(err).Stack().Str("variant", name).Str("storageKey", vr.Key).Msg(err.Error())
+			vr.Error = err
+			r.Variants[name] = vr
+			continue
+		}
+		vr.Url = storage.Url(vr.Key)
+		r.Variants[name] = vr
+	}
+	return r
+}
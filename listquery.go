@@ -0,0 +1,344 @@
+package irisx
+
+import (
+	"html"
+	"html/template"
+	urlUtil "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//query params of ListQuery: sort=-created,name or sort=name&order=desc, f.age=gt:18, f.status=in:1,2
+var (
+	SortParam    = "sort"
+	OrderParam   = "order"
+	FilterPrefix = "f."
+)
+
+//FilterOp operator of filter
+type FilterOp string
+
+const (
+	FilterEq   FilterOp = "eq"
+	FilterNe   FilterOp = "ne"
+	FilterGt   FilterOp = "gt"
+	FilterLt   FilterOp = "lt"
+	FilterIn   FilterOp = "in"
+	FilterLike FilterOp = "like"
+)
+
+//filterOpText shown in filter chips
+var filterOpText = map[FilterOp]string{FilterEq: "=", FilterNe: "≠", FilterGt: ">", FilterLt: "<", FilterIn: "∈", FilterLike: "~"}
+
+//FieldType type of filter values
+type FieldType int
+
+const (
+	FieldString FieldType = iota
+	FieldInt
+	FieldFloat
+	FieldBool
+	FieldTime
+)
+
+//ListTimeFormats accepted formats of FieldTime values
+var ListTimeFormats = []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339}
+
+//ListField a field of list, fields not declared can not be sorted or filtered
+type ListField struct {
+	Name  string
+	Type  FieldType
+	Label string
+	//Column sql column of field, eg: u.created_at, default Name
+	Column string
+	//Sortable allow sorting by it
+	Sortable bool
+	//Ops allowed filter operators, empty means not filterable
+	Ops []FilterOp
+}
+
+//ListSchema allow-list of sort and filter fields
+type ListSchema struct {
+	Fields []ListField
+	//DefaultSort used if sort is absent, eg: -created,id
+	DefaultSort string
+	//MaxSort max number of sort keys, default 3
+	MaxSort int
+	//MaxIn max number of in values, default 100
+	MaxIn int
+}
+
+func (s *ListSchema) field(name string) (ListField, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ListField{}, false
+}
+
+//SortKey a sort key of ListQuery
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+//Filter a condition of ListQuery, conditions are combined by and
+type Filter struct {
+	Field string
+	Op    FilterOp
+	//Value typed by field type: string, int64, float64, bool or time.Time, in values are []interface{}
+	Value interface{}
+	//Raw value in url
+	Raw string
+}
+
+//ListQuery sort, filters and page of a list request
+type ListQuery struct {
+	Sort    []SortKey
+	Filters []Filter
+	Page    PageParams
+	Schema  *ListSchema
+}
+
+//ListQuery parse sort, filters and page params by schema, invalid params are added to param errors and ignored
+func (ctx *Context) ListQuery(schema *ListSchema) *ListQuery {
+	q := &ListQuery{Schema: schema, Page: ctx.PageParams()}
+	sortParam := ctx.URLParam(SortParam)
+	fromUrl := sortParam != ""
+	if !fromUrl {
+		sortParam = schema.DefaultSort
+	}
+	maxSort := schema.MaxSort
+	if maxSort <= 0 {
+		maxSort = 3
+	}
+	for _, s := range strings.Split(sortParam, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		key := SortKey{Field: strings.TrimPrefix(s, "-"), Desc: strings.HasPrefix(s, "-")}
+		if f, ok := schema.field(key.Field); !ok || !f.Sortable {
+			ctx.AddParamError(SortParam, key.Field+" is not sortable.")
+			continue
+		}
+		if len(q.Sort) >= maxSort {
+			ctx.AddParamError(SortParam, "too many sort fields.")
+			break
+		}
+		q.Sort = append(q.Sort, key)
+	}
+	if fromUrl && len(q.Sort) == 1 {
+		v := ctx.CheckQuery(OrderParam).Optional()
+		v.In([]string{"asc", "desc"}, OrderParam+" should be asc or desc.")
+		if order := v.String(); order != "" {
+			q.Sort[0].Desc = order == "desc"
+		}
+	}
+	params := ctx.Request().URL.Query()
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if strings.HasPrefix(k, FilterPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	//stable order of filters
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, raw := range params[k] {
+			f, msg := schema.parseFilter(strings.TrimPrefix(k, FilterPrefix), raw)
+			if msg != "" {
+				ctx.AddParamError(k, msg)
+				continue
+			}
+			q.Filters = append(q.Filters, f)
+		}
+	}
+	return q
+}
+
+//parseFilter op:value, value without a known op means eq
+func (s *ListSchema) parseFilter(name, raw string) (Filter, string) {
+	field, ok := s.field(name)
+	if !ok || len(field.Ops) == 0 {
+		return Filter{}, name + " is not filterable."
+	}
+	f := Filter{Field: name, Op: FilterEq, Raw: raw}
+	value := raw
+	if i := strings.IndexByte(raw, ':'); i > 0 {
+		if _, known := filterOpText[FilterOp(raw[:i])]; known {
+			f.Op = FilterOp(raw[:i])
+			value = raw[i+1:]
+		}
+	}
+	allowed := false
+	for _, op := range field.Ops {
+		allowed = allowed || op == f.Op
+	}
+	if !allowed {
+		return Filter{}, name + " does not support " + string(f.Op) + "."
+	}
+	if f.Op == FilterLike && field.Type != FieldString {
+		return Filter{}, name + " does not support like."
+	}
+	if f.Op == FilterIn {
+		maxIn := s.MaxIn
+		if maxIn <= 0 {
+			maxIn = 100
+		}
+		parts := strings.Split(value, ",")
+		if len(parts) > maxIn {
+			return Filter{}, name + " has too many values."
+		}
+		values := make([]interface{}, len(parts))
+		for i, p := range parts {
+			v, ok := parseFieldValue(field.Type, p)
+			if !ok {
+				return Filter{}, name + " has invalid value: " + p + "."
+			}
+			values[i] = v
+		}
+		f.Value = values
+		return f, ""
+	}
+	v, ok := parseFieldValue(field.Type, value)
+	if !ok {
+		return Filter{}, name + " has invalid value: " + value + "."
+	}
+	f.Value = v
+	return f, ""
+}
+
+func parseFieldValue(t FieldType, s string) (interface{}, bool) {
+	switch t {
+	case FieldInt:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return v, err == nil
+	case FieldFloat:
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil
+	case FieldBool:
+		v, err := strconv.ParseBool(s)
+		return v, err == nil
+	case FieldTime:
+		for _, layout := range ListTimeFormats {
+			if v, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return v, true
+			}
+		}
+		return nil, false
+	}
+	return s, true
+}
+
+//SortOf sort key of field, nil if not sorted by it
+func (q *ListQuery) SortOf(field string) *SortKey {
+	for i := range q.Sort {
+		if q.Sort[i].Field == field {
+			return &q.Sort[i]
+		}
+	}
+	return nil
+}
+
+//FilterOf first filter of field, nil if absent
+func (q *ListQuery) FilterOf(field string) *Filter {
+	for i := range q.Filters {
+		if q.Filters[i].Field == field {
+			return &q.Filters[i]
+		}
+	}
+	return nil
+}
+
+//rewriteUrl like GetPageUrl: apply fn to query of url and reset page index of Page and DefaultPaginator
+func rewriteUrl(url string, fn func(q urlUtil.Values)) string {
+	u, err := urlUtil.ParseRequestURI(url)
+	if err != nil {
+		return "#"
+	}
+	q := u.Query()
+	fn(q)
+	q.Del(PageIndexParam)
+	q.Del(DefaultPaginator.indexParam())
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+//SortUrl url sorted by field only, toggle direction if already sorted by it
+func (q *ListQuery) SortUrl(url, field string) string {
+	desc := false
+	if len(q.Sort) > 0 && q.Sort[0].Field == field {
+		desc = !q.Sort[0].Desc
+	}
+	return rewriteUrl(url, func(v urlUtil.Values) {
+		v.Del(OrderParam)
+		if desc {
+			v.Set(SortParam, "-"+field)
+		} else {
+			v.Set(SortParam, field)
+		}
+	})
+}
+
+//RemoveFilterUrl url without the filter
+func (q *ListQuery) RemoveFilterUrl(url string, f Filter) string {
+	return rewriteUrl(url, func(v urlUtil.Values) {
+		key := FilterPrefix + f.Field
+		var rest []string
+		for _, raw := range v[key] {
+			if raw != f.Raw {
+				rest = append(rest, raw)
+			}
+		}
+		v.Del(key)
+		for _, raw := range rest {
+			v.Add(key, raw)
+		}
+	})
+}
+
+//SortHeader th of sortable column with aria-sort, plain th if field is not sortable
+func SortHeader(ctx *Context, q *ListQuery, field, label string) template.HTML {
+	if nil == ctx || nil == q {
+		return template.HTML(`<th scope="col">` + html.EscapeString(label) + `</th>`)
+	}
+	if f, ok := q.Schema.field(field); !ok || !f.Sortable {
+		return template.HTML(`<th scope="col">` + html.EscapeString(label) + `</th>`)
+	}
+	class, aria, icon := "sortable", "none", ""
+	if len(q.Sort) > 0 && q.Sort[0].Field == field {
+		if q.Sort[0].Desc {
+			class, aria, icon = "sortable sorted-desc", "descending", ` <span aria-hidden="true">▼</span>`
+		} else {
+			class, aria, icon = "sortable sorted-asc", "ascending", ` <span aria-hidden="true">▲</span>`
+		}
+	}
+	return template.HTML(`<th scope="col" class="` + class + `" aria-sort="` + aria + `"><a href="` + html.EscapeString(q.SortUrl(ctx.Request().RequestURI, field)) + `">` + html.EscapeString(label) + icon + `</a></th>`)
+}
+
+//FilterChips active filters with remove links
+func FilterChips(ctx *Context, q *ListQuery, classes string) template.HTML {
+	if nil == ctx || nil == q || len(q.Filters) == 0 {
+		return ""
+	}
+	url := ctx.Request().RequestURI
+	r := `<div class="filter-chips ` + classes + `">`
+	for _, f := range q.Filters {
+		label := f.Field
+		if field, ok := q.Schema.field(f.Field); ok && field.Label != "" {
+			label = field.Label
+		}
+		value := f.Raw
+		if i := strings.IndexByte(value, ':'); i > 0 && FilterOp(value[:i]) == f.Op {
+			value = value[i+1:]
+		}
+		text := label + " " + filterOpText[f.Op] + " " + value
+		r += `<span class="filter-chip">` + html.EscapeString(text) + ` <a href="` + html.EscapeString(q.RemoveFilterUrl(url, f)) + `" aria-label="` + html.EscapeString("移除 "+text) + `">×</a></span>`
+	}
+	r += `</div>`
+	return template.HTML(r)
+}
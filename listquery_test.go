@@ -0,0 +1,88 @@
+package irisx_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/kataras/iris/v12"
+)
+
+var userListSchema = &irisx.ListSchema{
+	Fields: []irisx.ListField{
+		{Name: "id", Type: irisx.FieldInt, Sortable: true, Ops: []irisx.FilterOp{irisx.FilterEq, irisx.FilterIn}},
+		{Name: "name", Label: "姓名", Sortable: true, Ops: []irisx.FilterOp{irisx.FilterEq, irisx.FilterLike}},
+		{Name: "age", Type: irisx.FieldInt, Label: "年龄", Sortable: true, Ops: []irisx.FilterOp{irisx.FilterGt, irisx.FilterLt}},
+		{Name: "vip", Type: irisx.FieldBool, Ops: []irisx.FilterOp{irisx.FilterEq, irisx.FilterNe, irisx.FilterLike}},
+		{Name: "created", Type: irisx.FieldTime, Sortable: true, Ops: []irisx.FilterOp{irisx.FilterGt}},
+		{Name: "password"},
+	},
+	DefaultSort: "-created,id",
+}
+
+//go test -run TestListQuery -v
+func TestListQuery(t *testing.T) {
+	app := newTestApp()
+	app.Get("/users", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		q := ctx.ListQuery(userListSchema)
+		var filters []string
+		for _, f := range q.Filters {
+			filters = append(filters, fmt.Sprintf("%s %s %T(%v)", f.Field, f.Op, f.Value, f.Value))
+		}
+		ctx.WriteString(fmt.Sprint(q.Sort, filters, ctx.ParamErrors()))
+	})
+	app.Get("/header", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		q := ctx.ListQuery(userListSchema)
+		ctx.WriteString(string(irisx.SortHeader(ctx, q, "name", "姓名")) + "\n" + string(irisx.SortHeader(ctx, q, "age", "年龄")) + "\n" +
+			string(irisx.SortHeader(ctx, q, "password", "密码")) + "\n" + string(irisx.FilterChips(ctx, q, "mb-2")))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	cases := [][2]string{
+		{"/users", "[{created true} {id false}] [] map[]"},
+		{"/users?sort=name&order=desc", "[{name true}] [] map[]"},
+		{"/users?sort=-age,password,name", "[{age true} {name false}] [] map[sort:password is not sortable.]"},
+		{"/users?sort=name&order=up", "[{name false}] [] map[order:order should be asc or desc.]"},
+		{"/users?f.age=gt:18&f.id=in:1,2&f.name=like:to:m", "[{created true} {id false}] [age gt int64(18) id in []interface {}([1 2]) name like string(to:m)] map[]"},
+		{"/users?f.name=a:b&f.vip=ne:true", "[{created true} {id false}] [name eq string(a:b) vip ne bool(true)] map[]"},
+		{"/users?f.age=18&f.vip=like:x&f.password=x&f.id=in:1,x", "[{created true} {id false}] [] map[f.age:age does not support eq. f.id:id has invalid value: x. f.password:password is not filterable. f.vip:vip does not support like.]"},
+	}
+	for _, c := range cases {
+		if _, bs := get(t, server.URL+c[0], nil); string(bs) != c[1] {
+			t.Fatal(c[0], "should be", c[1], "got", string(bs))
+		}
+	}
+	_, bs := get(t, server.URL+"/users?f.created=gt:2020-01-02", nil)
+	if !strings.Contains(string(bs), "created gt time.Time(2020-01-02 00:00:00") {
+		t.Fatal("time filter not match:", string(bs))
+	}
+
+	_, bs = get(t, server.URL+"/header?sort=name&pi=3&f.age=gt:18&f.name=like:tom", nil)
+	lines := strings.Split(string(bs), "\n")
+	expected := []string{
+		`<th scope="col" class="sortable sorted-asc" aria-sort="ascending"><a href="/header?f.age=gt%3A18&amp;f.name=like%3Atom&amp;sort=-name">姓名 <span aria-hidden="true">▲</span></a></th>`,
+		`<th scope="col" class="sortable" aria-sort="none"><a href="/header?f.age=gt%3A18&amp;f.name=like%3Atom&amp;sort=age">年龄</a></th>`,
+		`<th scope="col">密码</th>`,
+		`<div class="filter-chips mb-2"><span class="filter-chip">年龄 &gt; 18 <a href="/header?f.name=like%3Atom&amp;sort=name" aria-label="移除 年龄 &gt; 18">×</a></span>` +
+			`<span class="filter-chip">姓名 ~ tom <a href="/header?f.age=gt%3A18&amp;sort=name" aria-label="移除 姓名 ~ tom">×</a></span></div>`,
+	}
+	for i, e := range expected {
+		if lines[i] != e {
+			t.Fatal("line", i, "should be\n", e, "\ngot\n", lines[i])
+		}
+	}
+
+	irisx.DefaultPaginator.IndexParam = "page"
+	defer func() {
+		irisx.DefaultPaginator.IndexParam = ""
+	}()
+	_, bs = get(t, server.URL+"/header?sort=name&page=3&f.age=gt:18", nil)
+	lines = strings.Split(string(bs), "\n")
+	if e := `<th scope="col" class="sortable" aria-sort="none"><a href="/header?f.age=gt%3A18&amp;sort=age">年龄</a></th>`; lines[1] != e {
+		t.Fatal("page index of DefaultPaginator should be reset, got:", lines[1])
+	}
+}
@@ -0,0 +1,186 @@
+package irisx
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//SQLDialect dialect of ListSQL
+type SQLDialect int
+
+const (
+	MySQL SQLDialect = iota
+	Postgres
+	SQLite
+)
+
+var ErrBadColumn = errors.New("bad sql column")
+
+//columnReg column or table.column, nothing else can be spliced into sql
+var columnReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+//ListSQL sql of ListQuery, filters and sort are translated by columns of schema only, values are always bound as args
+type ListSQL struct {
+	query   *ListQuery
+	dialect SQLDialect
+	columns string
+	from    string
+	conds   []string
+	args    []interface{}
+}
+
+//SQL builder of q, columns and from are trusted sql written by developers, eg: q.SQL(irisx.MySQL, "id, name", "user")
+func (q *ListQuery) SQL(dialect SQLDialect, columns, from string) *ListSQL {
+	return &ListSQL{query: q, dialect: dialect, columns: columns, from: from}
+}
+
+//Where add a trusted condition with ? placeholders, eg: Where("deleted = ?", false)
+func (b *ListSQL) Where(cond string, args ...interface{}) *ListSQL {
+	b.conds = append(b.conds, "("+cond+")")
+	b.args = append(b.args, args...)
+	return b
+}
+
+//quote column by dialect
+func (b *ListSQL) quote(field string) (string, error) {
+	f, ok := b.query.Schema.field(field)
+	if !ok {
+		return "", ErrBadColumn
+	}
+	col := f.Column
+	if col == "" {
+		col = f.Name
+	}
+	if !columnReg.MatchString(col) {
+		log.Error().Func("quote").Str("column", col).Msg(ErrBadColumn.Error())
+		return "", ErrBadColumn
+	}
+	q := `"`
+	if b.dialect == MySQL {
+		q = "`"
+	}
+	parts := strings.Split(col, ".")
+	for i, p := range parts {
+		parts[i] = q + p + q
+	}
+	return strings.Join(parts, "."), nil
+}
+
+//escapeLike escape wildcards of like value with !
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+//where WHERE clause with ? placeholders
+func (b *ListSQL) where() (string, []interface{}, error) {
+	conds := append([]string{}, b.conds...)
+	args := append([]interface{}{}, b.args...)
+	for _, f := range b.query.Filters {
+		col, err := b.quote(f.Field)
+		if err != nil {
+			return "", nil, err
+		}
+		switch f.Op {
+		case FilterEq:
+			conds = append(conds, col+" = ?")
+		case FilterNe:
+			conds = append(conds, col+" <> ?")
+		case FilterGt:
+			conds = append(conds, col+" > ?")
+		case FilterLt:
+			conds = append(conds, col+" < ?")
+		case FilterLike:
+			conds = append(conds, col+" LIKE ? ESCAPE '!'")
+			args = append(args, "%"+escapeLike(f.Value.(string))+"%")
+			continue
+		case FilterIn:
+			values := f.Value.([]interface{})
+			conds = append(conds, col+" IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")+")")
+			args = append(args, values...)
+			continue
+		default:
+			return "", nil, errors.New("unknown filter op: " + string(f.Op))
+		}
+		args = append(args, f.Value)
+	}
+	if len(conds) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+//orderBy ORDER BY clause
+func (b *ListSQL) orderBy() (string, error) {
+	var keys []string
+	for _, s := range b.query.Sort {
+		col, err := b.quote(s.Field)
+		if err != nil {
+			return "", err
+		}
+		if s.Desc {
+			keys = append(keys, col+" DESC")
+		} else {
+			keys = append(keys, col+" ASC")
+		}
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	return " ORDER BY " + strings.Join(keys, ", "), nil
+}
+
+//Select SELECT columns FROM from WHERE ... ORDER BY ... LIMIT ... OFFSET ...
+func (b *ListSQL) Select() (string, []interface{}, error) {
+	where, args, err := b.where()
+	if err != nil {
+		return "", nil, err
+	}
+	order, err := b.orderBy()
+	if err != nil {
+		return "", nil, err
+	}
+	sql := "SELECT " + b.columns + " FROM " + b.from + where + order
+	if page := b.query.Page; page.Size > 0 {
+		//ints from PageParams, safe to splice
+		sql += " LIMIT " + strconv.Itoa(page.Size) + " OFFSET " + strconv.Itoa(page.Offset())
+	}
+	return b.rebind(sql), args, nil
+}
+
+//Count SELECT COUNT(*) FROM from WHERE ..., for the total of Page and OkPage
+func (b *ListSQL) Count() (string, []interface{}, error) {
+	where, args, err := b.where()
+	if err != nil {
+		return "", nil, err
+	}
+	return b.rebind("SELECT COUNT(*) FROM " + b.from + where), args, nil
+}
+
+//rebind ? to $n for postgres, quoted strings are skipped
+func (b *ListSQL) rebind(sql string) string {
+	if b.dialect != Postgres {
+		return sql
+	}
+	var sb strings.Builder
+	n := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,125 @@
+package irisx_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/kataras/iris/v12"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var sqlListSchema = &irisx.ListSchema{
+	Fields: []irisx.ListField{
+		{Name: "id", Type: irisx.FieldInt, Column: "u.id", Sortable: true, Ops: []irisx.FilterOp{irisx.FilterEq, irisx.FilterIn}},
+		{Name: "name", Sortable: true, Ops: []irisx.FilterOp{irisx.FilterEq, irisx.FilterLike}},
+		{Name: "age", Type: irisx.FieldInt, Sortable: true, Ops: []irisx.FilterOp{irisx.FilterGt, irisx.FilterLt, irisx.FilterNe}},
+	},
+	DefaultSort: "-age,id",
+}
+
+//go test -run TestListSQL -v
+func TestListSQL(t *testing.T) {
+	q := &irisx.ListQuery{
+		Schema:  sqlListSchema,
+		Sort:    []irisx.SortKey{{Field: "age", Desc: true}, {Field: "id"}},
+		Filters: []irisx.Filter{{Field: "age", Op: irisx.FilterGt, Value: int64(18)}, {Field: "id", Op: irisx.FilterIn, Value: []interface{}{int64(1), int64(2)}}, {Field: "name", Op: irisx.FilterLike, Value: "50%_off"}},
+		Page:    irisx.PageParams{Index: 2, Size: 10},
+	}
+	cases := map[irisx.SQLDialect]string{
+		irisx.MySQL:    "SELECT * FROM user u WHERE (u.deleted = ?) AND `age` > ? AND `u`.`id` IN (?, ?) AND `name` LIKE ? ESCAPE '!' ORDER BY `age` DESC, `u`.`id` ASC LIMIT 10 OFFSET 20",
+		irisx.Postgres: `SELECT * FROM user u WHERE (u.deleted = $1) AND "age" > $2 AND "u"."id" IN ($3, $4) AND "name" LIKE $5 ESCAPE '!' ORDER BY "age" DESC, "u"."id" ASC LIMIT 10 OFFSET 20`,
+		irisx.SQLite:   `SELECT * FROM user u WHERE (u.deleted = ?) AND "age" > ? AND "u"."id" IN (?, ?) AND "name" LIKE ? ESCAPE '!' ORDER BY "age" DESC, "u"."id" ASC LIMIT 10 OFFSET 20`,
+	}
+	for d, expected := range cases {
+		s, args, err := q.SQL(d, "*", "user u").Where("u.deleted = ?", false).Select()
+		if err != nil || s != expected {
+			t.Fatal(d, "should be\n", expected, "\ngot\n", s, err)
+		}
+		if r := fmt.Sprint(args); r != "[false 18 1 2 %50!%!_off%]" {
+			t.Fatal("args not match:", r)
+		}
+	}
+	s, _, _ := q.SQL(irisx.Postgres, "*", "user u").Count()
+	if s != `SELECT COUNT(*) FROM user u WHERE "age" > $1 AND "u"."id" IN ($2, $3) AND "name" LIKE $4 ESCAPE '!'` {
+		t.Fatal("count not match:", s)
+	}
+
+	bad := &irisx.ListSchema{Fields: []irisx.ListField{{Name: "name", Column: "name; drop table user", Sortable: true}}}
+	if _, _, err := (&irisx.ListQuery{Schema: bad, Sort: []irisx.SortKey{{Field: "name"}}}).SQL(irisx.MySQL, "*", "user").Select(); err != irisx.ErrBadColumn {
+		t.Fatal("bad column should be rejected:", err)
+	}
+	if _, _, err := (&irisx.ListQuery{Schema: sqlListSchema, Filters: []irisx.Filter{{Field: "password", Op: irisx.FilterEq, Value: "x"}}}).SQL(irisx.MySQL, "*", "user").Count(); err != irisx.ErrBadColumn {
+		t.Fatal("undeclared field should be rejected:", err)
+	}
+}
+
+//go test -run TestListSQLite -v
+func TestListSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 30; i++ {
+		name := fmt.Sprintf("user%02d", i)
+		if i == 7 {
+			name = "100%_sure"
+		}
+		if _, err := db.Exec(`INSERT INTO user (id, name, age) VALUES (?, ?, ?)`, i, name, 10+i%20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := newTestApp()
+	app.Get("/users", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		q := ctx.ListQuery(sqlListSchema)
+		b := q.SQL(irisx.SQLite, "u.id", "user u")
+		countSql, args, err := b.Count()
+		if err != nil {
+			ctx.WriteString(err.Error())
+			return
+		}
+		var total int
+		if err := db.QueryRow(countSql, args...).Scan(&total); err != nil {
+			ctx.WriteString(err.Error())
+			return
+		}
+		selectSql, args, _ := b.Select()
+		rows, err := db.Query(selectSql, args...)
+		if err != nil {
+			ctx.WriteString(err.Error())
+			return
+		}
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var id int
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		ctx.WriteString(fmt.Sprint(total, ids, ctx.ParamErrors()))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	cases := [][2]string{
+		{"/users?ps=5", "30 [19 18 17 16 15] map[]"},
+		{"/users?ps=5&pi=1", "30 [14 13 12 11 10] map[]"},
+		{"/users?f.age=gt:25&sort=id", "4 [16 17 18 19] map[]"},
+		{"/users?f.name=like:%25_&sort=id", "1 [7] map[]"},
+		{"/users?f.id=in:3,1,2&sort=-id&ps=2", "3 [3 2] map[]"},
+		{"/users?f.name=x'%20or%201=1--", "0 [] map[]"},
+		{"/users?sort=password,-id&ps=3&f.password=x", "30 [30 29 28] map[f.password:password is not filterable. sort:password is not sortable.]"},
+	}
+	for _, c := range cases {
+		if _, bs := get(t, server.URL+c[0], nil); string(bs) != c[1] {
+			t.Fatal(c[0], "should be", c[1], "got", string(bs))
+		}
+	}
+}
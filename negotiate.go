@@ -0,0 +1,308 @@
+package irisx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RocksonZeta/wrap/errs"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeXML      = "application/xml"
+	ContentTypeMsgPack  = "application/msgpack"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+var ErrNotProtoMessage = errors.New("data is not proto.Message")
+
+//ResponseEncoder write envelope to response, envelope is errs.Result for Ok and errs.Err for Err
+type ResponseEncoder func(ctx *Context, envelope interface{}) error
+
+type responseEncoder struct {
+	contentType string
+	encode      ResponseEncoder
+	//marshal envelope to bytes, used by streams like SSE, nil for registered encoders
+	marshal func(envelope interface{}) ([]byte, error)
+}
+
+//responseEncoders the first one is default
+var responseEncoders = []responseEncoder{
+	{ContentTypeJSON, encodeJSON, marshalJSON},
+	{"text/json", encodeJSON, marshalJSON},
+	{ContentTypeXML, encodeXML, marshalXML},
+	{"text/xml", encodeXML, marshalXML},
+	{ContentTypeMsgPack, encodeMsgPack, marshalMsgPack},
+	{"application/x-msgpack", encodeMsgPack, marshalMsgPack},
+	{"application/vnd.msgpack", encodeMsgPack, marshalMsgPack},
+	{ContentTypeProtobuf, encodeProtobuf, marshalProtobuf},
+	{"application/protobuf", encodeProtobuf, marshalProtobuf},
+}
+
+//RegisterResponseEncoder register encoder of content type at startup, replace the old one if exists
+func RegisterResponseEncoder(contentType string, encoder ResponseEncoder) {
+	for i, e := range responseEncoders {
+		if e.contentType == contentType {
+			responseEncoders[i].encode = encoder
+			responseEncoders[i].marshal = nil
+			return
+		}
+	}
+	responseEncoders = append(responseEncoders, responseEncoder{contentType, encoder, nil})
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+//parseAccept media ranges sorted by q desc
+func parseAccept(accept string) []acceptRange {
+	var r []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			r = append(r, acceptRange{mt, q})
+		}
+	}
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].q > r[j].q
+	})
+	return r
+}
+
+//negotiateEncoder the default encoder if nothing matched.
+//ranges of the same q are decided by the order of responseEncoders, and browsers preferring html get the default one.
+func negotiateEncoder(accept string) responseEncoder {
+	ranges := parseAccept(accept)
+	for i := 0; i < len(ranges); {
+		best := -1
+		j := i
+		for ; j < len(ranges) && ranges[j].q == ranges[i].q; j++ {
+			mt := ranges[j].mediaType
+			//browser default accept ends with xml and */*, json is expected by pages calling apis
+			if i == 0 && (mt == "text/html" || mt == "application/xhtml+xml") {
+				return responseEncoders[0]
+			}
+			if k := encoderIndex(mt); k >= 0 && (best < 0 || k < best) {
+				best = k
+			}
+		}
+		if best >= 0 {
+			return responseEncoders[best]
+		}
+		i = j
+	}
+	return responseEncoders[0]
+}
+
+//encoderIndex index of the first encoder matching media range, -1 if none
+func encoderIndex(mediaType string) int {
+	if mediaType == "*/*" {
+		return 0
+	}
+	for i, e := range responseEncoders {
+		if matchContentType(mediaType, e.contentType) {
+			return i
+		}
+	}
+	return -1
+}
+
+//Respond write envelope in the format negotiated by Accept header
+func (ctx *Context) Respond(envelope interface{}) {
+	e := negotiateEncoder(ctx.GetHeader("Accept"))
+	if err := e.encode(ctx, envelope); err != nil {
+		log.Error().Func("Respond").Err(err).Stack().Str("contentType", e.contentType).Msg(err.Error())
+		//builtin encoders write nothing when encoding failed, fallback to the default one
+		if e.contentType != responseEncoders[0].contentType {
+			responseEncoders[0].encode(ctx, envelope)
+		}
+	}
+}
+
+//resultOf envelope as errs.Result
+func resultOf(envelope interface{}) errs.Result {
+	switch e := envelope.(type) {
+	case errs.Result:
+		return e
+	case *errs.Result:
+		return *e
+	case errs.Err:
+		return e.Result()
+	case *errs.Err:
+		return e.Result()
+	}
+	return errs.Result{Data: envelope}
+}
+
+func encodeJSON(ctx *Context, envelope interface{}) error {
+	_, err := ctx.JSON(envelope)
+	return err
+}
+func marshalJSON(envelope interface{}) ([]byte, error) {
+	return json.Marshal(envelope)
+}
+
+//writeMarshaled write envelope marshaled by marshal
+func writeMarshaled(ctx *Context, contentType string, envelope interface{}, marshal func(envelope interface{}) ([]byte, error)) error {
+	bs, err := marshal(envelope)
+	if err != nil {
+		return err
+	}
+	ctx.ContentType(contentType)
+	_, err = ctx.Write(bs)
+	return err
+}
+
+func encodeMsgPack(ctx *Context, envelope interface{}) error {
+	return writeMarshaled(ctx, ContentTypeMsgPack, envelope, marshalMsgPack)
+}
+func marshalMsgPack(envelope interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(resultOf(envelope)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeProtobuf(ctx *Context, envelope interface{}) error {
+	return writeMarshaled(ctx, ContentTypeProtobuf, envelope, marshalProtobuf)
+}
+
+//marshalProtobuf envelope: message Result { int32 State = 1; bytes Data = 2; string Message = 3; }
+//Data should be proto.Message, []byte or nil
+func marshalProtobuf(envelope interface{}) ([]byte, error) {
+	r := resultOf(envelope)
+	var data []byte
+	switch d := r.Data.(type) {
+	case nil:
+	case []byte:
+		data = d
+	case proto.Message:
+		var err error
+		if data, err = proto.Marshal(d); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNotProtoMessage
+	}
+	buf := make([]byte, 0, len(data)+len(r.Message)+32)
+	if r.State != 0 {
+		buf = appendVarint(buf, 1<<3|0)
+		buf = appendVarint(buf, uint64(int64(r.State)))
+	}
+	if len(data) > 0 {
+		buf = appendVarint(buf, 2<<3|2)
+		buf = appendVarint(buf, uint64(len(data)))
+		buf = append(buf, data...)
+	}
+	if r.Message != "" {
+		buf = appendVarint(buf, 3<<3|2)
+		buf = appendVarint(buf, uint64(len(r.Message)))
+		buf = append(buf, r.Message...)
+	}
+	return buf, nil
+}
+
+func appendVarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	return append(buf, b[:n]...)
+}
+
+func encodeXML(ctx *Context, envelope interface{}) error {
+	return writeMarshaled(ctx, ContentTypeXML, envelope, marshalXML)
+}
+
+//marshalXML <Result><State>0</State><Data>...</Data><Message></Message></Result>, Data is encoded as its json form
+func marshalXML(envelope interface{}) ([]byte, error) {
+	r := resultOf(envelope)
+	var data interface{}
+	bs, err := json.Marshal(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(bs))
+	d.UseNumber()
+	if err = d.Decode(&data); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	start := xml.StartElement{Name: xml.Name{Local: "Result"}}
+	enc.EncodeToken(start)
+	enc.EncodeElement(r.State, xml.StartElement{Name: xml.Name{Local: "State"}})
+	if data != nil {
+		if err = encodeXMLValue(enc, "Data", data); err != nil {
+			return nil, err
+		}
+	}
+	if r.Message != "" {
+		enc.EncodeElement(r.Message, xml.StartElement{Name: xml.Name{Local: "Message"}})
+	}
+	enc.EncodeToken(start.End())
+	if err = enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var xmlNameReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+//encodeXMLValue value of json: map[string]interface{},[]interface{},string,json.Number,bool,nil
+func encodeXMLValue(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlNameReg.MatchString(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		enc.EncodeToken(start)
+		for _, k := range keys {
+			if err := encodeXMLValue(enc, k, v[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case []interface{}:
+		enc.EncodeToken(start)
+		for _, x := range v {
+			if err := encodeXMLValue(enc, "item", x); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		enc.EncodeToken(start)
+		return enc.EncodeToken(start.End())
+	}
+	return enc.EncodeElement(value, start)
+}
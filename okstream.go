@@ -0,0 +1,288 @@
+package irisx
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+const ContentTypeNDJSON = "application/x-ndjson"
+
+//StreamFlushEvery flush response after every n items
+var StreamFlushEvery = 100
+
+//Iterator return the next item, ok is false when finished
+type Iterator func() (item interface{}, ok bool, err error)
+
+//ChanIterator iterate a channel until it is closed, ch must be a receivable channel.
+//the producer should stop on ctx.Request().Context().Done(), nobody reads the channel after the client gone.
+func ChanIterator(ch interface{}) Iterator {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic("irisx: ChanIterator of " + v.Type().String())
+	}
+	return func() (interface{}, bool, error) {
+		item, ok := v.Recv()
+		if !ok {
+			return nil, false, nil
+		}
+		return item.Interface(), true, nil
+	}
+}
+
+//SliceIterator iterate a slice
+func SliceIterator(slice interface{}) Iterator {
+	v := reflect.ValueOf(slice)
+	i := 0
+	return func() (interface{}, bool, error) {
+		if i >= v.Len() {
+			return nil, false, nil
+		}
+		i++
+		return v.Index(i - 1).Interface(), true, nil
+	}
+}
+
+//streamWriter buffered writer which flushes every StreamFlushEvery items and stops when client gone
+type streamWriter struct {
+	ctx *Context
+	w   *bufio.Writer
+	n   int
+}
+
+func newStreamWriter(ctx *Context, contentType string) *streamWriter {
+	header := ctx.ResponseWriter().Header()
+	header.Set("Content-Type", contentType)
+	//disable buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	return &streamWriter{ctx: ctx, w: bufio.NewWriter(clientWriter{ctx})}
+}
+
+//clientWriter write errors of response mean the client has gone
+type clientWriter struct {
+	ctx *Context
+}
+
+func (w clientWriter) Write(p []byte) (int, error) {
+	n, err := w.ctx.Write(p)
+	if err != nil {
+		return n, ErrClientGone
+	}
+	return n, nil
+}
+
+//item count an item, flush if needed
+func (s *streamWriter) item() error {
+	select {
+	case <-s.ctx.Request().Context().Done():
+		return ErrClientGone
+	default:
+	}
+	s.n++
+	if StreamFlushEvery > 0 && s.n%StreamFlushEvery == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *streamWriter) flush() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	s.ctx.ResponseWriter().Flush()
+	return nil
+}
+
+//logStreamErr client gone is not an error of server
+func (ctx *Context) logStreamErr(funcName string, err error, n int) {
+	if err == ErrClientGone {
+		log.Warn().Func(funcName).Str("path", ctx.Path()).Int("items", n).Msg(err.Error())
+		return
+	}
+	log.Error().Func(funcName).Err(err).Stack().Str("path", ctx.Path()).Int("items", n).Msg(err.Error())
+}
+
+//OkStream stream items without loading all of them in memory.
+//respond NDJSON if Accept prefers application/x-ndjson, otherwise the Ok envelope {"Data":[...],"State":0},
+//State of the envelope is 500 with Message if iter failed in the middle.
+func (ctx *Context) OkStream(iter Iterator) error {
+	for _, a := range parseAccept(ctx.GetHeader("Accept")) {
+		if a.mediaType == ContentTypeNDJSON || a.mediaType == "application/jsonl" {
+			return ctx.OkNDJSON(iter)
+		}
+		if a.mediaType == "*/*" || matchContentType(a.mediaType, ContentTypeJSON) {
+			break
+		}
+	}
+	s := newStreamWriter(ctx, ContentTypeJSON+"; charset=utf-8")
+	s.w.WriteString(`{"Data":[`)
+	var err error
+	for {
+		item, ok, ierr := iter()
+		if ierr != nil {
+			err = ierr
+			break
+		}
+		if !ok {
+			break
+		}
+		//marshal before writing the separator, a failed item must leave the array valid
+		var bs []byte
+		if bs, err = json.Marshal(item); err != nil {
+			break
+		}
+		if s.n > 0 {
+			s.w.WriteByte(',')
+		}
+		s.w.Write(bs)
+		if err = s.item(); err != nil {
+			break
+		}
+	}
+	if err == ErrClientGone {
+		ctx.logStreamErr("OkStream", err, s.n)
+		return err
+	}
+	if err != nil {
+		ctx.logStreamErr("OkStream", err, s.n)
+		s.w.WriteString(`],"State":500,"Message":"stream aborted"}`)
+	} else {
+		s.w.WriteString(`],"State":0}`)
+	}
+	if ferr := s.flush(); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+//OkNDJSON stream items as newline delimited json
+func (ctx *Context) OkNDJSON(iter Iterator) error {
+	s := newStreamWriter(ctx, ContentTypeNDJSON)
+	enc := json.NewEncoder(s.w)
+	var err error
+	for {
+		item, ok, ierr := iter()
+		if ierr != nil {
+			err = ierr
+			break
+		}
+		if !ok {
+			break
+		}
+		if err = enc.Encode(item); err != nil {
+			break
+		}
+		if err = s.item(); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		ctx.logStreamErr("OkNDJSON", err, s.n)
+		//nothing can tell the client except a truncated stream
+		if err != ErrClientGone {
+			s.flush()
+		}
+		return err
+	}
+	return s.flush()
+}
+
+//CsvStream stream items as csv attachment filename, header is the first row if not empty.
+//item should be []string, []interface{} or a single value.
+func (ctx *Context) CsvStream(filename string, header []string, iter Iterator) error {
+	return ctx.csvStream(filename, header, false, iter)
+}
+
+func (ctx *Context) csvStream(filename string, header []string, bom bool, iter Iterator) error {
+	s := newStreamWriter(ctx, "text/csv; charset=utf-8")
+	if filename != "" {
+		ctx.Header("Content-Disposition", contentDisposition(filename))
+	}
+	if bom {
+		s.w.WriteString("\uFEFF")
+	}
+	w := csv.NewWriter(s.w)
+	var err error
+	if len(header) > 0 {
+		err = w.Write(header)
+	}
+	for err == nil {
+		item, ok, ierr := iter()
+		if ierr != nil {
+			err = ierr
+			break
+		}
+		if !ok {
+			break
+		}
+		if err = w.Write(csvRecord(item)); err != nil {
+			break
+		}
+		//csv.Writer buffers too, push the record to stream writer
+		w.Flush()
+		if err = s.item(); err != nil {
+			break
+		}
+	}
+	w.Flush()
+	if err != nil {
+		ctx.logStreamErr("CsvStream", err, s.n)
+		if err != ErrClientGone {
+			s.flush()
+		}
+		return err
+	}
+	return s.flush()
+}
+
+//csvRow record whose cells have been escaped
+type csvRow []string
+
+//csvRecord cells of item, text cells are escaped if ExportEscapeFormula
+func csvRecord(item interface{}) []string {
+	switch r := item.(type) {
+	case csvRow:
+		return r
+	case []string:
+		record := make([]string, len(r))
+		for i, v := range r {
+			record[i] = csvField(v)
+		}
+		return record
+	case []interface{}:
+		record := make([]string, len(r))
+		for i, v := range r {
+			record[i] = csvField(v)
+		}
+		return record
+	}
+	return []string{csvField(item)}
+}
+
+//csvField escaped text of csv cell, numbers are kept
+func csvField(v interface{}) string {
+	s := csvCell(v)
+	if _, ok := exportNumeric(v); !ok && ExportEscapeFormula {
+		return escapeFormula(s)
+	}
+	return s
+}
+
+func csvCell(v interface{}) string {
+	switch c := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case float64:
+		return strconv.FormatFloat(c, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(c), 'f', -1, 32)
+	case error:
+		return c.Error()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,126 @@
+package irisx
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"sort"
+)
+
+//PageSizeParam query param of page size
+var PageSizeParam = "ps"
+
+//DefaultPageSize page size if absent
+var DefaultPageSize = 20
+
+//MaxPageSize larger page sizes are capped to it
+var MaxPageSize = 100
+
+//PageSizeOptions options of page size selector
+var PageSizeOptions = []int{10, 20, 50, 100}
+
+//PageSizeLabel label format of page size options
+var PageSizeLabel = "%d 条/页"
+
+//RememberPageSize keep the chosen page size of each path in session for seconds, 0 means not remembered
+var RememberPageSize = 0
+
+//PageParams page index and size of request
+type PageParams struct {
+	//Index 0 based like pi
+	Index int
+	Size  int
+}
+
+//Offset of the first item, eg: LIMIT Size OFFSET Offset
+func (p PageParams) Offset() int {
+	return p.Index * p.Size
+}
+
+func (ctx *Context) pageSizeKey() string {
+	return ctx.Sid() + "/" + PageSizeParam + ":" + ctx.Path()
+}
+
+//PageParams read page index by DefaultPaginator and ps by CheckQuery, see Paginator.PageParams
+func (ctx *Context) PageParams() PageParams {
+	return DefaultPaginator.PageParams(ctx)
+}
+
+//PageParams read page index by IndexParam and OneBased of p, and ps by CheckQuery.
+//invalid values are added to param errors and replaced by defaults.
+//ps is capped to MaxPageSize, and remembered in session if RememberPageSize > 0.
+func (p *Paginator) PageParams(ctx *Context) PageParams {
+	name := p.indexParam()
+	first := 0
+	if p.OneBased {
+		first = 1
+	}
+	vi := ctx.CheckQuery(name).Optional()
+	pi := vi.Int(first, name+" should be an integer.")
+	if p.OneBased {
+		vi.Ensure(pi >= first, false, name+" should be positive.")
+	} else {
+		vi.Ensure(pi >= first, false, name+" should not be negative.")
+	}
+	if pi < first {
+		pi = first
+	}
+	return PageParams{Index: pi - first, Size: ctx.pageSize()}
+}
+
+//pageSize read ps, remembered one or default if absent
+func (ctx *Context) pageSize() int {
+	vs := ctx.CheckQuery(PageSizeParam).Optional()
+	remember := RememberPageSize > 0 && nil != ctx.SessionProvider && ctx.Sid() != ""
+	ps := vs.Int(0, PageSizeParam+" should be an integer.")
+	vs.Ensure(ps >= 0, false, PageSizeParam+" should not be negative.")
+	if ps > MaxPageSize {
+		ps = MaxPageSize
+	}
+	if ps > 0 && remember {
+		if err := ctx.SessionProvider.Set(ctx.pageSizeKey(), ps, RememberPageSize); err != nil {
+			log.Error().Func("pageSize").Err(err).Msg(err.Error())
+		}
+	}
+	if ps <= 0 && remember {
+		var saved int
+		if err := ctx.SessionProvider.Get(ctx.pageSizeKey(), &saved); err == nil && saved > 0 && saved <= MaxPageSize {
+			ps = saved
+		}
+	}
+	if ps <= 0 {
+		ps = DefaultPageSize
+	}
+	return ps
+}
+
+//PageSizeSelector a get form of page size select, other query params are kept and page index of DefaultPaginator is reset
+func PageSizeSelector(ctx *Context, current int, props string) template.HTML {
+	if nil == ctx {
+		return ""
+	}
+	r := `<form method="get" class="page-size-selector">`
+	q := ctx.Request().URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		if k != PageSizeParam && k != PageIndexParam && k != DefaultPaginator.indexParam() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range q[k] {
+			r += `<input type="hidden" name="` + html.EscapeString(k) + `" value="` + html.EscapeString(v) + `">`
+		}
+	}
+	options := make(map[int]string, len(PageSizeOptions)+1)
+	for _, n := range PageSizeOptions {
+		options[n] = fmt.Sprintf(PageSizeLabel, n)
+	}
+	if _, ok := options[current]; !ok && current > 0 {
+		options[current] = fmt.Sprintf(PageSizeLabel, current)
+	}
+	r += string(selector(options, current, false, `name="`+html.EscapeString(PageSizeParam)+`" onchange="this.form.submit()" `+props))
+	r += `<noscript><button type="submit">OK</button></noscript></form>`
+	return template.HTML(r)
+}
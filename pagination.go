@@ -6,13 +6,17 @@ import (
 	urlUtil "net/url"
 	"sort"
 	"strconv"
+	"strings"
 )
 
+//PageIndexParam query param of page index, 0 based
+var PageIndexParam = "pi"
+
 func Page(ctx *Context, ps int, total int64, showTotal bool, classes string) string {
 	if nil == ctx {
 		return ""
 	}
-	piName := "pi"
+	piName := PageIndexParam
 	url := ctx.Request().RequestURI
 	current := ctx.URLParamIntDefault(piName, 0)
 	if ps <= 0 {
@@ -139,3 +143,46 @@ func selector(options map[int]string, defaultValueObject interface{}, needEmptyO
 	r += `</select>`
 	return template.HTML(r)
 }
+
+//PageResult data of OkPage
+type PageResult struct {
+	Items interface{} `json:"items"`
+	Total int64       `json:"total"`
+	//Page current page index, 0 based like pi
+	Page      int `json:"page"`
+	PageSize  int `json:"pageSize"`
+	PageCount int `json:"pageCount"`
+}
+
+//OkPage respond items of page pi in Ok envelope, with RFC 5988 Link headers (first, prev, next, last) and X-Total-Count
+func (ctx *Context) OkPage(items interface{}, total int64, pi, ps int) {
+	pageCount := 0
+	if ps > 0 {
+		pageCount = int(math.Ceil(float64(total) / float64(ps)))
+	}
+	if items == nil {
+		items = []interface{}{}
+	}
+	ctx.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if link := PageLinks(ctx, pi, pageCount); link != "" {
+		ctx.Header("Link", link)
+	}
+	ctx.Ok(PageResult{Items: items, Total: total, Page: pi, PageSize: ps, PageCount: pageCount})
+}
+
+//PageLinks value of Link header, pages out of range are omitted
+func PageLinks(ctx *Context, pi, pageCount int) string {
+	url := ctx.Request().RequestURI
+	var links []string
+	for _, l := range []struct {
+		rel   string
+		index int
+	}{{"first", 0}, {"prev", pi - 1}, {"next", pi + 1}, {"last", pageCount - 1}} {
+		href := GetPageUrl(url, l.index, pageCount, PageIndexParam)
+		if href == "#" {
+			continue
+		}
+		links = append(links, "<"+ctx.AbsoluteURI(href)+`>; rel="`+l.rel+`"`)
+	}
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,280 @@
+package irisx
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	urlUtil "net/url"
+	"strconv"
+	"sync"
+)
+
+//bundled themes of Paginator
+const (
+	PageThemeBootstrap4 = "bootstrap4"
+	PageThemeBootstrap5 = "bootstrap5"
+	PageThemeBulma      = "bulma"
+	PageThemeTailwind   = "tailwind"
+)
+
+//PageLabels texts of pagination, Total and Page are fmt formats of a number
+type PageLabels struct {
+	Prev     string
+	Next     string
+	Ellipsis string
+	//Total eg: 共 %d 条
+	Total string
+	//Page aria-label of page links, eg: 第 %d 页
+	Page string
+	//Nav aria-label of nav
+	Nav string
+}
+
+//Paginator offset pagination renderer, zero values use defaults
+type Paginator struct {
+	//IndexParam query param of page index, default PageIndexParam
+	IndexParam string
+	//Window max number of page links including first and last, default 7
+	Window int
+	//OneBased page index in url starts from 1, default 0 like Page
+	OneBased bool
+	Labels   PageLabels
+	//Theme registered theme, default bootstrap4
+	Theme string
+	//Template overrides Theme, data is PageView
+	Template *template.Template
+	//ShowTotal render total of items
+	ShowTotal bool
+	//Class extra css classes of the list
+	Class string
+}
+
+//DefaultPaginator used by template func paginate
+var DefaultPaginator = &Paginator{}
+
+//DefaultPageLabels labels of empty Paginator.Labels fields
+var DefaultPageLabels = PageLabels{Prev: "上一页", Next: "下一页", Ellipsis: "…", Total: "共 %d 条", Page: "第 %d 页", Nav: "分页"}
+
+//PageLink a link of pagination
+type PageLink struct {
+	//Number page number shown to users, 1 based
+	Number int
+	//Url empty if disabled or ellipsis
+	Url      string
+	Label    string
+	Active   bool
+	Disabled bool
+	Ellipsis bool
+}
+
+//PageView data of pagination templates
+type PageView struct {
+	Prev  PageLink
+	Next  PageLink
+	Pages []PageLink
+	//Current 0 based page index
+	Current   int
+	PageCount int
+	Total     int64
+	TotalText string
+	ShowTotal bool
+	Class     string
+	Labels    PageLabels
+}
+
+var pageThemes sync.Map
+
+//RegisterPageTheme register or override a pagination theme at startup, data of tpl is PageView
+func RegisterPageTheme(name, tpl string) {
+	pageThemes.Store(name, template.Must(template.New(name).Parse(tpl)))
+}
+
+func init() {
+	RegisterPageTheme(PageThemeBootstrap4, `<nav aria-label="{{.Labels.Nav}}"><ul class="pagination{{with .Class}} {{.}}{{end}}">`+
+		`{{with .Prev}}{{if .Disabled}}<li class="page-item disabled"><span class="page-link" aria-disabled="true">{{$.Labels.Prev}}</span></li>{{else}}<li class="page-item"><a class="page-link" href="{{.Url}}" rel="prev">{{$.Labels.Prev}}</a></li>{{end}}{{end}}`+
+		`{{range .Pages}}{{if .Ellipsis}}<li class="page-item disabled"><span class="page-link">{{$.Labels.Ellipsis}}</span></li>`+
+		`{{else if .Active}}<li class="page-item active" aria-current="page"><span class="page-link">{{.Number}}<span class="sr-only">(current)</span></span></li>`+
+		`{{else}}<li class="page-item"><a class="page-link" href="{{.Url}}" aria-label="{{.Label}}">{{.Number}}</a></li>{{end}}{{end}}`+
+		`{{with .Next}}{{if .Disabled}}<li class="page-item disabled"><span class="page-link" aria-disabled="true">{{$.Labels.Next}}</span></li>{{else}}<li class="page-item"><a class="page-link" href="{{.Url}}" rel="next">{{$.Labels.Next}}</a></li>{{end}}{{end}}`+
+		`</ul>{{if .ShowTotal}}<span class="pagination-total">{{.TotalText}}</span>{{end}}</nav>`)
+	RegisterPageTheme(PageThemeBootstrap5, `<nav aria-label="{{.Labels.Nav}}"><ul class="pagination{{with .Class}} {{.}}{{end}}">`+
+		`{{with .Prev}}{{if .Disabled}}<li class="page-item disabled"><a class="page-link" aria-disabled="true" tabindex="-1">{{$.Labels.Prev}}</a></li>{{else}}<li class="page-item"><a class="page-link" href="{{.Url}}" rel="prev">{{$.Labels.Prev}}</a></li>{{end}}{{end}}`+
+		`{{range .Pages}}{{if .Ellipsis}}<li class="page-item disabled"><span class="page-link">{{$.Labels.Ellipsis}}</span></li>`+
+		`{{else if .Active}}<li class="page-item active"><a class="page-link" href="{{.Url}}" aria-current="page" aria-label="{{.Label}}">{{.Number}}</a></li>`+
+		`{{else}}<li class="page-item"><a class="page-link" href="{{.Url}}" aria-label="{{.Label}}">{{.Number}}</a></li>{{end}}{{end}}`+
+		`{{with .Next}}{{if .Disabled}}<li class="page-item disabled"><a class="page-link" aria-disabled="true" tabindex="-1">{{$.Labels.Next}}</a></li>{{else}}<li class="page-item"><a class="page-link" href="{{.Url}}" rel="next">{{$.Labels.Next}}</a></li>{{end}}{{end}}`+
+		`</ul>{{if .ShowTotal}}<span class="pagination-total">{{.TotalText}}</span>{{end}}</nav>`)
+	RegisterPageTheme(PageThemeBulma, `<nav class="pagination{{with .Class}} {{.}}{{end}}" role="navigation" aria-label="{{.Labels.Nav}}">`+
+		`{{with .Prev}}<a class="pagination-previous"{{if .Disabled}} disabled aria-disabled="true"{{else}} href="{{.Url}}" rel="prev"{{end}}>{{$.Labels.Prev}}</a>{{end}}`+
+		`{{with .Next}}<a class="pagination-next"{{if .Disabled}} disabled aria-disabled="true"{{else}} href="{{.Url}}" rel="next"{{end}}>{{$.Labels.Next}}</a>{{end}}`+
+		`<ul class="pagination-list">{{range .Pages}}{{if .Ellipsis}}<li><span class="pagination-ellipsis">{{$.Labels.Ellipsis}}</span></li>`+
+		`{{else}}<li><a class="pagination-link{{if .Active}} is-current{{end}}" href="{{.Url}}" aria-label="{{.Label}}"{{if .Active}} aria-current="page"{{end}}>{{.Number}}</a></li>{{end}}{{end}}</ul>`+
+		`{{if .ShowTotal}}<span class="pagination-total">{{.TotalText}}</span>{{end}}</nav>`)
+	RegisterPageTheme(PageThemeTailwind, `<nav aria-label="{{.Labels.Nav}}" class="flex items-center gap-4"><ul class="inline-flex -space-x-px text-sm{{with .Class}} {{.}}{{end}}">`+
+		`{{with .Prev}}<li>{{if .Disabled}}<span class="px-3 py-2 rounded-l-md border border-gray-300 bg-white text-gray-300 cursor-not-allowed" aria-disabled="true">{{$.Labels.Prev}}</span>{{else}}<a class="px-3 py-2 rounded-l-md border border-gray-300 bg-white text-gray-500 hover:bg-gray-100" href="{{.Url}}" rel="prev">{{$.Labels.Prev}}</a>{{end}}</li>{{end}}`+
+		`{{range .Pages}}<li>{{if .Ellipsis}}<span class="px-3 py-2 border border-gray-300 bg-white text-gray-500">{{$.Labels.Ellipsis}}</span>`+
+		`{{else if .Active}}<a class="px-3 py-2 border border-blue-300 bg-blue-50 text-blue-600 z-10" href="{{.Url}}" aria-current="page" aria-label="{{.Label}}">{{.Number}}</a>`+
+		`{{else}}<a class="px-3 py-2 border border-gray-300 bg-white text-gray-500 hover:bg-gray-100" href="{{.Url}}" aria-label="{{.Label}}">{{.Number}}</a>{{end}}</li>{{end}}`+
+		`{{with .Next}}<li>{{if .Disabled}}<span class="px-3 py-2 rounded-r-md border border-gray-300 bg-white text-gray-300 cursor-not-allowed" aria-disabled="true">{{$.Labels.Next}}</span>{{else}}<a class="px-3 py-2 rounded-r-md border border-gray-300 bg-white text-gray-500 hover:bg-gray-100" href="{{.Url}}" rel="next">{{$.Labels.Next}}</a>{{end}}</li>{{end}}`+
+		`</ul>{{if .ShowTotal}}<span class="text-sm text-gray-500">{{.TotalText}}</span>{{end}}</nav>`)
+}
+
+func (p *Paginator) indexParam() string {
+	if p.IndexParam != "" {
+		return p.IndexParam
+	}
+	return PageIndexParam
+}
+
+func (p *Paginator) labels() PageLabels {
+	l := p.Labels
+	d := DefaultPageLabels
+	if l.Prev == "" {
+		l.Prev = d.Prev
+	}
+	if l.Next == "" {
+		l.Next = d.Next
+	}
+	if l.Ellipsis == "" {
+		l.Ellipsis = d.Ellipsis
+	}
+	if l.Total == "" {
+		l.Total = d.Total
+	}
+	if l.Page == "" {
+		l.Page = d.Page
+	}
+	if l.Nav == "" {
+		l.Nav = d.Nav
+	}
+	return l
+}
+
+//Current 0 based page index of request
+func (p *Paginator) Current(ctx *Context) int {
+	pi := ctx.URLParamIntDefault(p.indexParam(), 0)
+	if p.OneBased {
+		pi--
+	}
+	if pi < 0 {
+		return 0
+	}
+	return pi
+}
+
+//Indexes page indexes shown: first, last and pages around current, -1 means ellipsis
+func (p *Paginator) Indexes(current, pageCount int) []int {
+	w := p.Window
+	if w <= 0 {
+		w = 7
+	}
+	if w < 5 {
+		//first, ellipsis, current, ellipsis, last
+		w = 5
+	}
+	var r []int
+	if pageCount <= w {
+		for i := 0; i < pageCount; i++ {
+			r = append(r, i)
+		}
+		return r
+	}
+	//pages between the two ellipses
+	n := w - 4
+	start := current - (n-1)/2
+	switch {
+	case start <= 2:
+		for i := 0; i < w-2; i++ {
+			r = append(r, i)
+		}
+		r = append(r, -1)
+	case start+n-1 >= pageCount-3:
+		r = append(r, 0, -1)
+		for i := pageCount - w + 2; i < pageCount-1; i++ {
+			r = append(r, i)
+		}
+	default:
+		r = append(r, 0, -1)
+		for i := start; i < start+n; i++ {
+			r = append(r, i)
+		}
+		r = append(r, -1)
+	}
+	return append(r, pageCount-1)
+}
+
+//pageUrl url of page index, with OneBased applied
+func (p *Paginator) pageUrl(url string, index int) string {
+	u, err := urlUtil.ParseRequestURI(url)
+	if err != nil {
+		return "#"
+	}
+	q := u.Query()
+	if p.OneBased {
+		index++
+	}
+	q.Set(p.indexParam(), strconv.Itoa(index))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+//View data of rendering
+func (p *Paginator) View(ctx *Context, ps int, total int64) PageView {
+	labels := p.labels()
+	pageCount := 0
+	if ps > 0 {
+		pageCount = int(math.Ceil(float64(total) / float64(ps)))
+	}
+	current := p.Current(ctx)
+	url := ctx.Request().RequestURI
+	v := PageView{Current: current, PageCount: pageCount, Total: total, ShowTotal: p.ShowTotal, Class: p.Class, Labels: labels}
+	v.TotalText = fmt.Sprintf(labels.Total, total)
+	v.Prev = PageLink{Number: current, Disabled: current <= 0 || current > pageCount}
+	if !v.Prev.Disabled {
+		v.Prev.Url = p.pageUrl(url, current-1)
+	}
+	v.Next = PageLink{Number: current + 2, Disabled: current+1 >= pageCount}
+	if !v.Next.Disabled {
+		v.Next.Url = p.pageUrl(url, current+1)
+	}
+	for _, i := range p.Indexes(current, pageCount) {
+		if i < 0 {
+			v.Pages = append(v.Pages, PageLink{Ellipsis: true, Disabled: true})
+			continue
+		}
+		v.Pages = append(v.Pages, PageLink{Number: i + 1, Url: p.pageUrl(url, i), Label: fmt.Sprintf(labels.Page, i+1), Active: i == current})
+	}
+	return v
+}
+
+//Render pagination by Template or Theme, nothing if there is only one page and no total shown
+func (p *Paginator) Render(ctx *Context, ps int, total int64) (template.HTML, error) {
+	if nil == ctx {
+		return "", nil
+	}
+	v := p.View(ctx, ps, total)
+	if v.PageCount <= 1 && !v.ShowTotal {
+		return "", nil
+	}
+	tpl := p.Template
+	if tpl == nil {
+		theme := p.Theme
+		if theme == "" {
+			theme = PageThemeBootstrap4
+		}
+		t, ok := pageThemes.Load(theme)
+		if !ok {
+			err := fmt.Errorf("pagination theme %s not registered", theme)
+			log.Error().Func("Render").Err(err).Stack().Msg(err.Error())
+			return "", err
+		}
+		tpl = t.(*template.Template)
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, v); err != nil {
+		log.Error().Func("Render").Err(err).Stack().Msg(err.Error())
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,163 @@
+package irisx_test
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/kataras/iris/v12"
+	irisctx "github.com/kataras/iris/v12/context"
+)
+
+//go test -run TestPaginatorIndexes -v
+func TestPaginatorIndexes(t *testing.T) {
+	p := &irisx.Paginator{}
+	cases := map[[2]int]string{
+		{0, 5}:   "[0 1 2 3 4]",
+		{0, 20}:  "[0 1 2 3 4 -1 19]",
+		{3, 20}:  "[0 1 2 3 4 -1 19]",
+		{4, 20}:  "[0 -1 3 4 5 -1 19]",
+		{10, 20}: "[0 -1 9 10 11 -1 19]",
+		{16, 20}: "[0 -1 15 16 17 18 19]",
+		{19, 20}: "[0 -1 15 16 17 18 19]",
+	}
+	for c, expected := range cases {
+		if r := fmt.Sprint(p.Indexes(c[0], c[1])); r != expected {
+			t.Fatal("indexes of", c, "should be", expected, "got", r)
+		}
+	}
+	p.Window = 9
+	if r := fmt.Sprint(p.Indexes(10, 20)); r != "[0 -1 8 9 10 11 12 -1 19]" {
+		t.Fatal("window 9 not match:", r)
+	}
+}
+
+//go test -run TestPaginator -v
+func TestPaginator(t *testing.T) {
+	oneBased := &irisx.Paginator{IndexParam: "page", OneBased: true, Theme: irisx.PageThemeBulma, ShowTotal: true, Labels: irisx.PageLabels{Prev: "Prev", Next: "Next", Total: "%d items", Page: "Page %d", Nav: "pagination"}}
+	custom := &irisx.Paginator{Template: template.Must(template.New("").Parse(`{{range .Pages}}{{if .Active}}[{{.Number}}]{{else if .Ellipsis}}..{{else}}{{.Number}}{{end}} {{end}}`))}
+	app := newTestApp()
+	app.Get("/default", func(ictx iris.Context) {
+		html, _ := irisx.DefaultPaginator.Render(ictx.(*irisx.Context), 10, 200)
+		ictx.WriteString(string(html))
+	})
+	app.Get("/bulma", func(ictx iris.Context) {
+		html, _ := oneBased.Render(ictx.(*irisx.Context), 10, 35)
+		ictx.WriteString(string(html))
+	})
+	app.Get("/custom", func(ictx iris.Context) {
+		html, _ := custom.Render(ictx.(*irisx.Context), 10, 200)
+		ictx.WriteString(string(html))
+	})
+	app.Get("/themes", func(ictx iris.Context) {
+		for _, theme := range []string{irisx.PageThemeBootstrap4, irisx.PageThemeBootstrap5, irisx.PageThemeBulma, irisx.PageThemeTailwind, "none"} {
+			html, err := (&irisx.Paginator{Theme: theme}).Render(ictx.(*irisx.Context), 10, 200)
+			ictx.WriteString(theme + ":" + fmt.Sprint(err == nil && strings.Contains(string(html), `aria-current="page"`)) + "\n")
+		}
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	_, bs := get(t, server.URL+"/default?pi=0&q=go", nil)
+	html := string(bs)
+	for _, s := range []string{
+		`<nav aria-label="分页"><ul class="pagination">`,
+		`<li class="page-item disabled"><span class="page-link" aria-disabled="true">上一页</span></li>`,
+		`<li class="page-item active" aria-current="page"><span class="page-link">1<span class="sr-only">(current)</span></span></li>`,
+		`<a class="page-link" href="/default?pi=1&amp;q=go" aria-label="第 2 页">2</a>`,
+		`<a class="page-link" href="/default?pi=19&amp;q=go" aria-label="第 20 页">20</a>`,
+		`<a class="page-link" href="/default?pi=1&amp;q=go" rel="next">下一页</a>`,
+	} {
+		if !strings.Contains(html, s) {
+			t.Fatal("bootstrap4 should contain", s, "\n", html)
+		}
+	}
+	_, bs = get(t, server.URL+"/bulma?page=4", nil)
+	html = string(bs)
+	for _, s := range []string{
+		`<a class="pagination-previous" href="/bulma?page=3" rel="prev">Prev</a>`,
+		`<a class="pagination-next" disabled aria-disabled="true">Next</a>`,
+		`<a class="pagination-link is-current" href="/bulma?page=4" aria-label="Page 4" aria-current="page">4</a>`,
+		`<span class="pagination-total">35 items</span>`,
+	} {
+		if !strings.Contains(html, s) {
+			t.Fatal("bulma should contain", s, "\n", html)
+		}
+	}
+	_, bs = get(t, server.URL+"/custom?pi=10", nil)
+	if string(bs) != "1 .. 10 [11] 12 .. 20 " {
+		t.Fatal("custom template not match:", string(bs))
+	}
+	_, bs = get(t, server.URL+"/themes?pi=3", nil)
+	if string(bs) != "bootstrap4:true\nbootstrap5:true\nbulma:true\ntailwind:true\nnone:false\n" {
+		t.Fatal("themes not match:", string(bs))
+	}
+}
+
+//go test -run TestPageParams -v
+func TestPageParams(t *testing.T) {
+	irisx.RememberPageSize = 3600
+	defer func() {
+		irisx.RememberPageSize = 0
+	}()
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.ContextPool.Attach(func() irisctx.Context {
+		return &irisx.Context{Context: irisctx.NewContext(app), SessionProvider: &Sessions{}}
+	})
+	app.Get("/list", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		p := ctx.PageParams()
+		ctx.WriteString(fmt.Sprint(p.Index, " ", p.Size, " ", p.Offset(), " ", ctx.ParamErrors()))
+	})
+	app.Get("/onebased", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		p := (&irisx.Paginator{IndexParam: "page", OneBased: true}).PageParams(ctx)
+		ctx.WriteString(fmt.Sprint(p.Index, " ", p.Size, " ", p.Offset(), " ", ctx.ParamErrors()))
+	})
+	app.Get("/selector", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		ctx.WriteString(string(irisx.PageSizeSelector(ctx, ctx.PageParams().Size, `class="form-select"`)))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+	cookie := map[string]string{"Cookie": "token1=pp1"}
+	defer delete(sessionValues, "pp1/ps:/list")
+
+	cases := [][2]string{
+		{"/list", "0 20 0 map[]"},
+		{"/list?pi=2&ps=10", "2 10 20 map[]"},
+		//remembered
+		{"/list?pi=1", "1 10 10 map[]"},
+		{"/list?ps=1000", "0 100 0 map[]"},
+		{"/list?pi=-1&ps=x", "0 100 0 map[pi:pi should not be negative. ps:ps should be an integer.]"},
+		{"/list?ps=-1", "0 100 0 map[ps:ps should not be negative.]"},
+		{"/onebased?ps=10", "0 10 0 map[]"},
+		{"/onebased?page=3&ps=10&pi=9", "2 10 20 map[]"},
+		{"/onebased?page=0&ps=10", "0 10 0 map[page:page should be positive.]"},
+	}
+	for _, c := range cases {
+		if _, bs := get(t, server.URL+c[0], cookie); string(bs) != c[1] {
+			t.Fatal(c[0], "should be", c[1], "got", string(bs))
+		}
+	}
+	if _, bs := get(t, server.URL+"/list?pi=1", nil); string(bs) != "1 20 20 map[]" {
+		t.Fatal("page size should not be remembered without session:", string(bs))
+	}
+	_, bs := get(t, server.URL+"/selector?q=a%26b&pi=3&ps=50&tag=x&tag=y", nil)
+	html := string(bs)
+	for _, s := range []string{
+		`<input type="hidden" name="q" value="a&amp;b"><input type="hidden" name="tag" value="x"><input type="hidden" name="tag" value="y">`,
+		`<select name="ps" onchange="this.form.submit()" class="form-select">`,
+		`<option value="50" selected="selected" >50 条/页</option>`,
+	} {
+		if !strings.Contains(html, s) {
+			t.Fatal("selector should contain", s, "\n", html)
+		}
+	}
+	if strings.Contains(html, `name="pi"`) {
+		t.Fatal("page index should be reset:", html)
+	}
+}
@@ -0,0 +1,105 @@
+package irisx
+
+import (
+	"net/http"
+
+	"github.com/RocksonZeta/wrap/errs"
+	"github.com/kataras/iris/v12/context"
+)
+
+//ProblemType RFC 7807 problem type of an errs.Err state
+type ProblemType struct {
+	//Type uri of problem type, default about:blank
+	Type   string
+	Title  string
+	Status int
+}
+
+var problemTypes = map[int]ProblemType{}
+
+//DefaultProblemStatus http status of states which are not registered and not http status
+var DefaultProblemStatus = http.StatusBadRequest
+
+//RegisterProblemType map errs.Err state to problem type at startup, eg: RegisterProblemType(1001,ProblemType{Type:"/problems/out-of-stock",Title:"Out of stock",Status:409})
+func RegisterProblemType(state int, t ProblemType) {
+	problemTypes[state] = t
+}
+
+//problemTypeOf registered type, or http status if state is one
+func problemTypeOf(state int) ProblemType {
+	if t, ok := problemTypes[state]; ok {
+		if t.Status == 0 {
+			t.Status = DefaultProblemStatus
+		}
+		return t
+	}
+	status := state
+	if status < 400 || status > 599 {
+		status = DefaultProblemStatus
+	}
+	return ProblemType{Status: status}
+}
+
+//NewProblem build problem of state, data can be detail string, errs.Err, param errors or any extension data
+func (ctx *Context) NewProblem(state int, data interface{}) context.Problem {
+	return ctx.newProblem(state, problemTypeOf(state), data)
+}
+
+func (ctx *Context) newProblem(state int, t ProblemType, data interface{}) context.Problem {
+	title := t.Title
+	if title == "" {
+		title = http.StatusText(t.Status)
+	}
+	p := context.NewProblem().Status(t.Status).Title(title).Instance(ctx.Request().URL.RequestURI())
+	if t.Type != "" {
+		p.Type(t.Type)
+	}
+	if state != t.Status {
+		p.Key("state", state)
+	}
+	switch d := data.(type) {
+	case nil:
+	case string:
+		p.Detail(d)
+	case errs.Err:
+		if d.Message != "" {
+			p.Detail(d.Message)
+		}
+		if d.Data != nil {
+			p.Key("data", d.Data)
+		}
+	case *errs.Err:
+		if d.Message != "" {
+			p.Detail(d.Message)
+		}
+		if d.Data != nil {
+			p.Key("data", d.Data)
+		}
+	case error:
+		p.Detail(d.Error())
+	case map[string]string:
+		p.Key("errors", d)
+	default:
+		p.Key("data", d)
+	}
+	if pe := ctx.ParamErrors(); len(pe) > 0 {
+		p.Key("errors", pe)
+	}
+	return p
+}
+
+//ErrProblem respond application/problem+json (or problem+xml if client prefers xml) with real http status
+func (ctx *Context) ErrProblem(state int, data interface{}) {
+	ctx.writeProblem(ctx.NewProblem(state, data))
+}
+
+func (ctx *Context) writeProblem(p context.Problem) {
+	opts := context.ProblemOptions{JSON: context.JSON{Indent: " "}}
+	if negotiateEncoder(ctx.GetHeader("Accept")).contentType == ContentTypeXML {
+		opts.RenderXML = true
+		opts.XML = context.XML{Indent: " "}
+	}
+	if _, err := ctx.Problem(p, opts); err != nil {
+		log.Error().Func("writeProblem").Err(err).Stack().Msg(err.Error())
+	}
+}
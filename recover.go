@@ -0,0 +1,114 @@
+package irisx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/kataras/iris/v12"
+)
+
+//RequestIdHeader header of request id, it will be generated if absent
+var RequestIdHeader = "X-Request-Id"
+
+//RequestKeyRequestId values key of request id
+const RequestKeyRequestId = "RequestId"
+
+//OnPanic will be called with every recovered panic, can be used to forward panics to an error reporting sink, eg: sentry.
+var OnPanic func(ctx *Context, err *PanicError)
+
+//PanicError panic recovered by Recover
+type PanicError struct {
+	//Value argument of panic
+	Value interface{}
+	//Stack stack trace of the panic goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprint("panic: ", e.Value)
+}
+
+//Unwrap the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+//RequestId id of request from RequestIdHeader, generate one if absent
+func (ctx *Context) RequestId() string {
+	if id := ctx.Values().GetString(RequestKeyRequestId); id != "" {
+		return id
+	}
+	id := ctx.GetHeader(RequestIdHeader)
+	if id == "" || len(id) > 128 {
+		id = RandomName(16)
+	}
+	ctx.Values().Set(RequestKeyRequestId, id)
+	return id
+}
+
+//uidOf uid for logging, nil if no session
+func (ctx *Context) uidOf() interface{} {
+	if nil == ctx.SessionProvider {
+		return nil
+	}
+	if uid := ctx.Values().Get(ctx.SessionProvider.UidKey()); uid != nil {
+		return uid
+	}
+	var uid interface{}
+	ctx.GetUid(&uid)
+	return uid
+}
+
+//Recover middleware of panics in handlers: log the panic, call OnPanic, then respond 500 error page for html requests or Err envelope for others.
+//usage: app.UseGlobal(irisx.Recover)
+func Recover(ictx iris.Context) {
+	ctx := ictx.(*Context)
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		//net/http aborts the response silently
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		ctx.recovered(&PanicError{Value: v, Stack: debug.Stack()})
+	}()
+	ctx.Next()
+}
+
+func (ctx *Context) recovered(perr *PanicError) {
+	id := ctx.RequestId()
+	log.Error().Func("Recover").Err(perr).Str("requestId", id).Str("method", ctx.Method()).Str("path", ctx.Path()).Interface("uid", ctx.uidOf()).Str("stack", string(perr.Stack)).Msg(perr.Error())
+	if nil != OnPanic {
+		ctx.callOnPanic(perr)
+	}
+	ctx.StopExecution()
+	if ctx.ResponseWriter().Written() > 0 {
+		//response has been sent partially, nothing to do
+		return
+	}
+	ctx.Header(RequestIdHeader, id)
+	m := ErrorMapping{Status: http.StatusInternalServerError, State: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+	if ctx.WantsHTML() {
+		ctx.errorPage(m, perr, perr.Stack)
+		return
+	}
+	ctx.respondMapping(m)
+}
+
+//callOnPanic a broken sink should not break the response
+func (ctx *Context) callOnPanic(perr *PanicError) {
+	defer func() {
+		if v := recover(); v != nil {
+			err := errors.New(fmt.Sprint(v))
+			log.Error().Func("OnPanic").Err(err).Stack().Msg(err.Error())
+		}
+	}()
+	OnPanic(ctx, perr)
+}
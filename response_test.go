@@ -0,0 +1,492 @@
+package irisx_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/RocksonZeta/wrap/errs"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func get(t *testing.T, url string, header map[string]string) (*http.Response, []byte) {
+	req, _ := http.NewRequest("GET", url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	bs, _ := ioutil.ReadAll(res.Body)
+	return res, bs
+}
+
+//go test -run TestNegotiate -v
+func TestNegotiate(t *testing.T) {
+	app := newTestApp()
+	app.Get("/user", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Ok(map[string]interface{}{"name": "tom", "tags": []string{"a", "b"}})
+	})
+	app.Get("/pb", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Ok(&wrappers.StringValue{Value: "tom"})
+	})
+	app.Get("/err", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Err(2, "bad")
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	res, bs := get(t, server.URL+"/user", nil)
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") || string(bs) != `{"State":0,"Data":{"name":"tom","tags":["a","b"]}}` {
+		t.Fatal("default should be json, got:", string(bs))
+	}
+	res, bs = get(t, server.URL+"/user", map[string]string{"Accept": "text/html;q=0.9, application/xml"})
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/xml") || !strings.Contains(string(bs), `<Result><State>0</State><Data><name>tom</name><tags><item>a</item><item>b</item></tags></Data></Result>`) {
+		t.Fatal("xml not match, got:", string(bs))
+	}
+	res, bs = get(t, server.URL+"/user", map[string]string{"Accept": "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"})
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		t.Fatal("browser should get json, got:", string(bs))
+	}
+	res, bs = get(t, server.URL+"/user", map[string]string{"Accept": "application/xml, application/json"})
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		t.Fatal("equal q should prefer json, got:", string(bs))
+	}
+	_, bs = get(t, server.URL+"/err", map[string]string{"Accept": "application/msgpack"})
+	var r map[string]interface{}
+	if err := msgpack.Unmarshal(bs, &r); err != nil || r["State"] != int8(2) || r["Data"] != "bad" {
+		t.Fatal("msgpack not match, got:", r, err)
+	}
+	_, bs = get(t, server.URL+"/pb", map[string]string{"Accept": "application/x-protobuf"})
+	data, _ := proto.Marshal(&wrappers.StringValue{Value: "tom"})
+	if string(bs) != string(append([]byte{2<<3 | 2, byte(len(data))}, data...)) {
+		t.Fatal("protobuf not match, got:", bs)
+	}
+	res, bs = get(t, server.URL+"/user", map[string]string{"Accept": "application/x-protobuf"})
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		t.Fatal("non proto data should fallback to json, got:", string(bs))
+	}
+}
+
+//go test -run TestProblem -v
+func TestProblem(t *testing.T) {
+	irisx.RegisterProblemType(1001, irisx.ProblemType{Type: "/problems/out-of-stock", Title: "Out of stock", Status: http.StatusConflict})
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.ContextPool.Attach(func() context.Context {
+		return &irisx.Context{Context: context.NewContext(app), ProblemDetails: true}
+	})
+	app.Get("/stock", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Err(1001, "sold out")
+	})
+	app.Get("/form", func(ctx iris.Context) {
+		c := ctx.(*irisx.Context)
+		c.CheckQuery("email").NotEmpty()
+		c.Err(http.StatusUnprocessableEntity, nil)
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	res, bs := get(t, server.URL+"/stock", nil)
+	var p map[string]interface{}
+	json.Unmarshal(bs, &p)
+	if res.StatusCode != http.StatusConflict || res.Header.Get("Content-Type") != "application/problem+json; charset=UTF-8" {
+		t.Fatal("problem status or content type not match:", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	if p["type"] != server.URL+"/problems/out-of-stock" || p["title"] != "Out of stock" || p["detail"] != "sold out" || p["state"] != float64(1001) || p["instance"] != server.URL+"/stock" {
+		t.Fatal("problem not match:", string(bs))
+	}
+	res, bs = get(t, server.URL+"/form", nil)
+	p = nil
+	json.Unmarshal(bs, &p)
+	if res.StatusCode != http.StatusUnprocessableEntity || p["errors"] == nil || p["state"] != nil {
+		t.Fatal("param errors should be in problem:", string(bs))
+	}
+}
+
+type quotaError struct {
+	limit int
+}
+
+func (e *quotaError) Error() string {
+	return "quota exceeded"
+}
+
+//go test -run TestFail -v
+func TestFail(t *testing.T) {
+	irisx.RegisterErrorType((*quotaError)(nil), irisx.ErrorMapping{Status: http.StatusTooManyRequests, State: 429001})
+	app := newTestApp()
+	app.Get("/missing", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(fmt.Errorf("user 1: %w", irisx.ErrNotFound))
+	})
+	app.Get("/quota", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(fmt.Errorf("upload: %w", &quotaError{10}))
+	})
+	app.Get("/user-error", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(errs.NewUserError(3, "name is taken"))
+	})
+	app.Get("/wrapped-user-error", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(fmt.Errorf("register: %w", errs.NewUserError(3, "name is taken")))
+	})
+	app.Get("/internal", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(fmt.Errorf("query: %w", errs.Err{State: 7, Message: "select * from users: connection refused"}))
+	})
+	app.Get("/boom", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(errors.New("db is down"))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	cases := []struct {
+		path    string
+		status  int
+		state   float64
+		message string
+	}{
+		{"/missing", 404, 404, "user 1: not found"},
+		{"/quota", 429, 429001, "upload: quota exceeded"},
+		{"/user-error", 400, 3, "name is taken"},
+		{"/wrapped-user-error", 400, 3, "name is taken"},
+		{"/internal", 500, 7, "Internal Server Error"},
+		{"/boom", 500, 500, "Internal Server Error"},
+	}
+	for _, c := range cases {
+		res, bs := get(t, server.URL+c.path, nil)
+		var r map[string]interface{}
+		json.Unmarshal(bs, &r)
+		if res.StatusCode != c.status || r["State"] != c.state || r["Message"] != c.message {
+			t.Fatal(c.path, "not match:", res.StatusCode, string(bs))
+		}
+	}
+	res, bs := get(t, server.URL+"/missing", map[string]string{"Accept": "text/html,application/xhtml+xml,*/*;q=0.8"})
+	if res.StatusCode != 404 || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Fatal("html request should get error page:", res.StatusCode, string(bs))
+	}
+}
+
+//go test -run TestErrorPage -v
+func TestErrorPage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "irisx")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "errors"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "errors", "404.html"), []byte(`custom {{.Error.Status}} {{.Title}}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "errors", "500.html"), []byte(`broken {{template "none"}}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "errors", "default.html"), []byte(`default {{.Error.Status}} {{.Error.Detail}}`), 0644)
+
+	var beforeViews []string
+	app := newTestApp()
+	app.RegisterView(iris.HTML(dir, ".html"))
+	irisx.ErrorPages(app)
+	app.Get("/boom", func(ctx iris.Context) {
+		ctx.(*irisx.Context).BeforeView = func(ctx *irisx.Context, tplFile string) {
+			beforeViews = append(beforeViews, tplFile)
+		}
+		ctx.(*irisx.Context).Fail(errors.New("db is down"))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+	html := map[string]string{"Accept": "text/html"}
+
+	res, bs := get(t, server.URL+"/none", html)
+	if res.StatusCode != 404 || string(bs) != "custom 404 404 Not Found" {
+		t.Fatal("404 page not match:", res.StatusCode, string(bs))
+	}
+	res, bs = get(t, server.URL+"/boom", html)
+	if res.StatusCode != 500 || string(bs) != "default 500 " {
+		t.Fatal("broken 500 page should fallback to default:", res.StatusCode, string(bs))
+	}
+	if len(beforeViews) != 1 {
+		t.Fatal("BeforeView should be called once for the fallback chain, got:", beforeViews)
+	}
+	irisx.Development = true
+	defer func() {
+		irisx.Development = false
+	}()
+	_, bs = get(t, server.URL+"/boom", html)
+	if string(bs) != "default 500 db is down" {
+		t.Fatal("detail should be shown in development:", string(bs))
+	}
+	res, bs = get(t, server.URL+"/none", nil)
+	if res.StatusCode != 404 || !strings.Contains(string(bs), `"State":404`) {
+		t.Fatal("api should get envelope:", res.StatusCode, string(bs))
+	}
+
+	app = newTestApp()
+	app.Get("/boom", func(ctx iris.Context) {
+		ctx.(*irisx.Context).Fail(errors.New("<db> is down"))
+	})
+	server2 := serveTestApp(app)
+	defer server2.Close()
+	res, bs = get(t, server2.URL+"/boom", html)
+	if res.StatusCode != 500 || !strings.Contains(string(bs), "<h1>500 Internal Server Error</h1>") || !strings.Contains(string(bs), "&lt;db&gt; is down") {
+		t.Fatal("builtin page not match:", res.StatusCode, string(bs))
+	}
+}
+
+//go test -run TestRecover -v
+func TestRecover(t *testing.T) {
+	var reported *irisx.PanicError
+	irisx.OnPanic = func(ctx *irisx.Context, err *irisx.PanicError) {
+		reported = err
+		panic("sink is broken")
+	}
+	defer func() {
+		irisx.OnPanic = nil
+	}()
+	app := newTestApp()
+	app.UseGlobal(irisx.Recover)
+	app.Get("/panic", func(ctx iris.Context) {
+		panic(errors.New("nil map"))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	res, bs := get(t, server.URL+"/panic", map[string]string{"X-Request-Id": "req-1"})
+	if res.StatusCode != 500 || res.Header.Get("X-Request-Id") != "req-1" || !strings.Contains(string(bs), `"State":500`) {
+		t.Fatal("api should get Err envelope:", res.StatusCode, string(bs))
+	}
+	if reported == nil || reported.Unwrap().Error() != "nil map" || !strings.Contains(string(reported.Stack), "TestRecover") {
+		t.Fatal("panic should be reported:", reported)
+	}
+	res, bs = get(t, server.URL+"/panic", map[string]string{"Accept": "text/html"})
+	if res.StatusCode != 500 || !strings.Contains(string(bs), "<h1>500 Internal Server Error</h1>") || len(res.Header.Get("X-Request-Id")) != 32 {
+		t.Fatal("html should get error page:", res.StatusCode, string(bs))
+	}
+}
+
+//go test -run TestSSE -v
+func TestSSE(t *testing.T) {
+	irisx.SSEHeartbeat = 20 * time.Millisecond
+	defer func() {
+		irisx.SSEHeartbeat = 15 * time.Second
+	}()
+	gone := make(chan error, 1)
+	release := make(chan struct{})
+	app := newTestApp()
+	app.Get("/events", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		start, _ := strconv.Atoi(ctx.LastEventId())
+		ctx.SSE(func(send func(event, id string, data interface{}) error) error {
+			for i := start + 1; i <= 2; i++ {
+				if err := send("tick", strconv.Itoa(i), map[string]int{"n": i}); err != nil {
+					return err
+				}
+			}
+			//keep the stream open until the client has seen a heartbeat
+			<-release
+			return nil
+		})
+	})
+	app.Get("/unmarshalable", func(ictx iris.Context) {
+		items := []interface{}{1, 2, make(chan int)}
+		ictx.(*irisx.Context).OkStream(irisx.SliceIterator(items))
+	})
+	app.Get("/forever", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		gone <- ctx.SSE(func(send func(event, id string, data interface{}) error) error {
+			if err := send("", "", "x"); err != nil {
+				return err
+			}
+			<-ctx.Request().Context().Done()
+			return send("", "", "y")
+		})
+	})
+	app.Get("/xml", func(ictx iris.Context) {
+		ictx.(*irisx.Context).SSE(func(send func(event, id string, data interface{}) error) error {
+			return send("", "", "x")
+		})
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Header.Get("Content-Type") != "text/event-stream" || res.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatal("headers not match:", res.Header)
+	}
+	r := bufio.NewReader(res.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal("stream ended:", lines, err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	if e := readEvent(); e != ":ok\n" {
+		t.Fatal("stream should open:", e)
+	}
+	if e := readEvent(); e != "event: tick\nid: 2\ndata: {\"State\":0,\"Data\":{\"n\":2}}\n" {
+		t.Fatal("events not match:", e)
+	}
+	if e := readEvent(); e != ":\n" {
+		t.Fatal("heartbeat should be sent:", e)
+	}
+	close(release)
+	bs, _ := ioutil.ReadAll(r)
+	res.Body.Close()
+	if strings.Contains(string(bs), "data:") {
+		t.Fatal("no more events:", string(bs))
+	}
+
+	res, err = http.Get(server.URL + "/forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = bufio.NewReader(res.Body)
+	readEvent()
+	if e := readEvent(); e != "data: {\"State\":0,\"Data\":\"x\"}\n" {
+		t.Fatal("first event not match:", e)
+	}
+	res.Body.Close()
+	select {
+	case err := <-gone:
+		if err != irisx.ErrClientGone {
+			t.Fatal("should stop with ErrClientGone:", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SSE should stop when client disconnected")
+	}
+
+	_, bs = get(t, server.URL+"/xml", map[string]string{"Accept": "text/event-stream, application/xml"})
+	if string(bs) != ":ok\n\ndata: <?xml version=\"1.0\" encoding=\"UTF-8\"?>\ndata: <Result><State>0</State><Data>x</Data></Result>\n\n" {
+		t.Fatal("event data should be negotiated xml:", string(bs))
+	}
+}
+
+//go test -run TestOkStream -v
+func TestOkStream(t *testing.T) {
+	gone := make(chan error, 1)
+	app := newTestApp()
+	app.Get("/users", func(ictx iris.Context) {
+		ch := make(chan map[string]int)
+		go func() {
+			defer close(ch)
+			for i := 1; i <= 3; i++ {
+				ch <- map[string]int{"id": i}
+			}
+		}()
+		ictx.(*irisx.Context).OkStream(irisx.ChanIterator(ch))
+	})
+	app.Get("/broken", func(ictx iris.Context) {
+		i := 0
+		ictx.(*irisx.Context).OkStream(func() (interface{}, bool, error) {
+			i++
+			if i > 1 {
+				return nil, false, errors.New("db is down")
+			}
+			return i, true, nil
+		})
+	})
+	app.Get("/unmarshalable", func(ictx iris.Context) {
+		items := []interface{}{1, 2, make(chan int)}
+		ictx.(*irisx.Context).OkStream(irisx.SliceIterator(items))
+	})
+	app.Get("/forever", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		gone <- ctx.OkNDJSON(func() (interface{}, bool, error) {
+			return strings.Repeat("x", 1024), true, nil
+		})
+	})
+	app.Get("/csv", func(ictx iris.Context) {
+		rows := [][]interface{}{{1, "tom, jr", 1.5}, {2, `say "hi"`, nil}, {-3, "=1+1", "-2"}}
+		ictx.(*irisx.Context).CsvStream("users.csv", []string{"id", "name", "score"}, irisx.SliceIterator(rows))
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	res, bs := get(t, server.URL+"/users", nil)
+	var result errs.Result
+	if err := json.Unmarshal(bs, &result); err != nil || result.State != 0 || fmt.Sprint(result.Data) != "[map[id:1] map[id:2] map[id:3]]" || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		t.Fatal("json array not match:", err, string(bs))
+	}
+	res, bs = get(t, server.URL+"/users", map[string]string{"Accept": "application/x-ndjson"})
+	if res.Header.Get("Content-Type") != "application/x-ndjson" || string(bs) != "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n" {
+		t.Fatal("ndjson not match:", string(bs))
+	}
+	_, bs = get(t, server.URL+"/broken", nil)
+	if err := json.Unmarshal(bs, &result); err != nil || result.State != 500 || fmt.Sprint(result.Data) != "[1]" {
+		t.Fatal("broken stream should end with state 500:", err, string(bs))
+	}
+	_, bs = get(t, server.URL+"/unmarshalable", nil)
+	if err := json.Unmarshal(bs, &result); err != nil || result.State != 500 || fmt.Sprint(result.Data) != "[1 2]" {
+		t.Fatal("unmarshalable item should end the stream with valid json:", err, string(bs))
+	}
+	res, bs = get(t, server.URL+"/csv", nil)
+	if res.Header.Get("Content-Disposition") != `attachment; filename="users.csv"` || string(bs) != "id,name,score\n1,\"tom, jr\",1.5\n2,\"say \"\"hi\"\"\",\n-3,'=1+1,'-2\n" {
+		t.Fatal("csv not match:", res.Header, string(bs))
+	}
+
+	res, err := http.Get(server.URL + "/forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bufio.NewReader(res.Body).ReadString('\n')
+	res.Body.Close()
+	select {
+	case err := <-gone:
+		if err != irisx.ErrClientGone {
+			t.Fatal("should stop with ErrClientGone:", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream should stop when client disconnected")
+	}
+}
+
+//go test -run TestOkPage -v
+func TestOkPage(t *testing.T) {
+	app := newTestApp()
+	app.Get("/users", func(ictx iris.Context) {
+		ctx := ictx.(*irisx.Context)
+		pi := ctx.URLParamIntDefault("pi", 0)
+		ctx.OkPage([]string{"tom", "jerry"}, 45, pi, 10)
+	})
+	app.Get("/empty", func(ictx iris.Context) {
+		ictx.(*irisx.Context).OkPage(nil, 0, 0, 10)
+	})
+	server := serveTestApp(app)
+	defer server.Close()
+
+	res, bs := get(t, server.URL+"/users?q=a&pi=2", nil)
+	if string(bs) != `{"State":0,"Data":{"items":["tom","jerry"],"total":45,"page":2,"pageSize":10,"pageCount":5}}` {
+		t.Fatal("page result not match:", string(bs))
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	expected := `<http://` + host + `/users?pi=0&q=a>; rel="first", <http://` + host + `/users?pi=1&q=a>; rel="prev", <http://` + host + `/users?pi=3&q=a>; rel="next", <http://` + host + `/users?pi=4&q=a>; rel="last"`
+	if res.Header.Get("Link") != expected || res.Header.Get("X-Total-Count") != "45" {
+		t.Fatal("headers not match:", res.Header.Get("Link"), res.Header.Get("X-Total-Count"))
+	}
+	res, _ = get(t, server.URL+"/users?pi=4", nil)
+	if link := res.Header.Get("Link"); strings.Contains(link, `rel="next"`) || !strings.Contains(link, `pi=3>; rel="prev"`) {
+		t.Fatal("last page should have no next:", link)
+	}
+	res, bs = get(t, server.URL+"/empty", nil)
+	if res.Header.Get("Link") != "" || res.Header.Get("X-Total-Count") != "0" || !strings.Contains(string(bs), `"items":[]`) {
+		t.Fatal("empty page not match:", res.Header, string(bs))
+	}
+}
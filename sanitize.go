@@ -0,0 +1,118 @@
+package irisx
+
+import (
+	"html"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+//builtin sanitize policies
+const (
+	SanitizeStrict   = "strict"
+	SanitizeUGC      = "ugc"
+	SanitizeComment  = "comment"
+	SanitizeRichText = "richtext"
+)
+
+var sanitizePolicies = map[string]*bluemonday.Policy{
+	SanitizeStrict:   bluemonday.StrictPolicy(),
+	SanitizeUGC:      bluemonday.UGCPolicy(),
+	SanitizeComment:  commentPolicy(),
+	SanitizeRichText: richTextPolicy(),
+}
+
+//OnSanitize will be called when sanitization changed the input, can be used to log suspicious submissions.
+var OnSanitize func(ctx *Context, key, policy, before, after string)
+
+//comment policy: basic inline formatting and links
+func commentPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	p.AllowElements("b", "strong", "i", "em", "u", "s", "del", "code", "br", "p", "blockquote")
+	p.AllowLists()
+	return p
+}
+
+//rich text policy: rich editor content with images and tables
+func richTextPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowImages()
+	p.AllowDataURIImages()
+	p.AllowTables()
+	p.AllowAttrs("class").Globally()
+	p.AllowElements("figure", "figcaption", "span")
+	return p
+}
+
+//RegisterSanitizePolicy register a named policy, should be called at startup. eg: RegisterSanitizePolicy("bio",bluemonday.StrictPolicy())
+func RegisterSanitizePolicy(name string, policy *bluemonday.Policy) {
+	sanitizePolicies[name] = policy
+}
+
+//SanitizePolicy get policy by name, nil if not registered.
+func SanitizePolicy(name string) *bluemonday.Policy {
+	return sanitizePolicies[name]
+}
+
+//SanitizeString sanitize s with the named policy, unregistered policy falls back to strict.
+func SanitizeString(policy, s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	p, ok := sanitizePolicies[policy]
+	if !ok {
+		log.Error().Func("SanitizeString").Str("policy", policy).Msg("sanitize policy not found, use strict policy")
+		p = sanitizePolicies[SanitizeStrict]
+	}
+	return p.Sanitize(s)
+}
+
+//sanitizeChanged compare unescaped text, policies escape & < > in plain text which is not a change of content
+func sanitizeChanged(before, after string) bool {
+	return html.UnescapeString(before) != html.UnescapeString(after)
+}
+
+//Sanitize sanitize value with the named policy, eg: v.Sanitize("richtext").String()
+func (v *Validator) Sanitize(policy string) *Validator {
+	if v.goon {
+		before := v.value
+		v.value = SanitizeString(policy, before)
+		if sanitizeChanged(before, v.value) {
+			v.sanitized = true
+			if nil != OnSanitize {
+				OnSanitize(v.ctx, v.key, policy, before, v.value)
+			}
+		}
+	}
+	return v
+}
+
+//Sanitized report whether sanitization changed the input
+func (v *Validator) Sanitized() bool {
+	return v.sanitized
+}
+
+//Sanitize sanitize every value with the named policy
+func (v *ValidatorValues) Sanitize(policy string) *ValidatorValues {
+	if v.goon {
+		v.own()
+		for i, before := range v.values {
+			v.values[i] = SanitizeString(policy, before)
+			if sanitizeChanged(before, v.values[i]) {
+				v.sanitized = true
+				if nil != OnSanitize {
+					OnSanitize(v.ctx, v.keyAt(i), policy, before, v.values[i])
+				}
+			}
+		}
+	}
+	return v
+}
+
+//Sanitized report whether sanitization changed any input
+func (v *ValidatorValues) Sanitized() bool {
+	return v.sanitized
+}
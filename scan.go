@@ -0,0 +1,186 @@
+package irisx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+//ScanResult result of scanning a file
+type ScanResult struct {
+	Clean bool
+	//Threat name of the threat found, eg: Eicar-Test-Signature
+	Threat string
+}
+
+//FileScanner scan upload files for malware or policy violations
+type FileScanner interface {
+	Scan(r io.Reader) (ScanResult, error)
+}
+
+//DefaultFileScanner scan every file before ValidatorFile.Save, Copy and StreamUpload store it, nil means no scan.
+var DefaultFileScanner FileScanner
+
+//ScanFailOpen accept files when the scanner fails, default reject them.
+var ScanFailOpen = false
+
+//ClamAV client of clamd INSTREAM protocol
+type ClamAV struct {
+	//Network "tcp" or "unix"
+	Network string
+	Address string
+	Timeout time.Duration
+	//ChunkSize size of stream chunk, should be less than StreamMaxLength of clamd
+	ChunkSize int
+}
+
+func NewClamAV(network, address string) *ClamAV {
+	return &ClamAV{Network: network, Address: address, Timeout: 30 * time.Second, ChunkSize: 64 << 10}
+}
+
+func (c *ClamAV) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(c.Network, c.Address, c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	return conn, nil
+}
+
+//command send a null terminated command and read the reply
+func (c *ClamAV) command(cmd string, body io.Reader) (string, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("z" + cmd + "\x00")); err != nil {
+		return "", err
+	}
+	if body != nil {
+		if err = c.stream(conn, body); err != nil {
+			return "", err
+		}
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", err
+	}
+	return strings.TrimRight(reply, "\x00\n"), nil
+}
+
+//stream write body as chunks: 4 bytes big endian length + data, end with zero length
+func (c *ClamAV) stream(w io.Writer, body io.Reader) error {
+	size := c.ChunkSize
+	if size <= 0 {
+		size = 64 << 10
+	}
+	buf := make([]byte, 4+size)
+	for {
+		n, err := io.ReadFull(body, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+//Ping check clamd is alive
+func (c *ClamAV) Ping() error {
+	reply, err := c.command("PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return errors.New("clamav: bad reply: " + reply)
+	}
+	return nil
+}
+
+//Scan scan r by INSTREAM, reply: "stream: OK" or "stream: Eicar-Test-Signature FOUND"
+func (c *ClamAV) Scan(r io.Reader) (ScanResult, error) {
+	reply, err := c.command("INSTREAM", r)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	reply = strings.TrimPrefix(reply, "stream: ")
+	if reply == "OK" {
+		return ScanResult{Clean: true}, nil
+	}
+	if strings.HasSuffix(reply, " FOUND") {
+		return ScanResult{Threat: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return ScanResult{}, errors.New("clamav: " + reply)
+}
+
+//checkScan record scan result as param error of key, return false if the file should be rejected
+func (ctx *Context) checkScan(key string, r ScanResult, err error, msg []string) bool {
+	if err != nil {
+		log.Error().Func("Scan").Err(err).Stack().Str("key", key).Msg(err.Error())
+		if ScanFailOpen {
+			return true
+		}
+		ctx.AddParamError(key, formatMsg(key+" can not be scanned.", msg))
+		return false
+	}
+	if !r.Clean {
+		log.Warn().Func("Scan").Str("key", key).Str("threat", r.Threat).Msg("threat found")
+		ctx.AddParamError(key, formatMsg(key+" failed security scan.", msg))
+		return false
+	}
+	return true
+}
+
+func formatMsg(defaultMsg string, msg []string) string {
+	if len(msg) > 0 {
+		return msg[0]
+	}
+	return defaultMsg
+}
+
+//Scan scan file by scanner, and rewind it
+func (v *ValidatorFile) Scan(scanner FileScanner, msg ...string) *ValidatorFile {
+	if !v.goon || v.file == nil || scanner == nil {
+		return v
+	}
+	r, err := scanner.Scan(v.file)
+	if _, serr := v.file.Seek(0, io.SeekStart); err == nil {
+		err = serr
+	}
+	v.scanned = true
+	if !v.ctx.checkScan(v.key, r, err, msg) {
+		v.goon = false
+	}
+	return v
+}
+
+//scanDefault scan file by DefaultFileScanner once, return false if the file is rejected
+func (v *ValidatorFile) scanDefault() bool {
+	if !v.scanned && DefaultFileScanner != nil {
+		v.Scan(DefaultFileScanner)
+	}
+	return v.goon
+}
+
+//ScannerFunc adapter of ordinary function as FileScanner, eg: policy checks
+type ScannerFunc func(r io.Reader) (ScanResult, error)
+
+func (f ScannerFunc) Scan(r io.Reader) (ScanResult, error) {
+	return f(r)
+}
@@ -0,0 +1,158 @@
+package irisx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/RocksonZeta/irisx"
+)
+
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+//fakeClamd serve PING and INSTREAM of clamd protocol
+func fakeClamd(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			cmd, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			switch cmd {
+			case "zPING\x00":
+				conn.Write([]byte("PONG\x00"))
+			case "zINSTREAM\x00":
+				data := &bytes.Buffer{}
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					io.CopyN(data, r, int64(size))
+				}
+				if bytes.Contains(data.Bytes(), eicar) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}
+		}(conn)
+	}
+}
+
+//putRecorder record sizes of Put
+type putRecorder struct {
+	*irisx.MemoryStorage
+	sizes []int64
+}
+
+func (s *putRecorder) Put(key string, r io.Reader, size int64, contentType string) error {
+	s.sizes = append(s.sizes, size)
+	return s.MemoryStorage.Put(key, r, size, contentType)
+}
+
+//go test -run TestClamAV -v
+func TestClamAV(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go fakeClamd(l)
+	clam := irisx.NewClamAV("tcp", l.Addr().String())
+	clam.ChunkSize = 16
+	if err := clam.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := clam.Scan(bytes.NewReader(append([]byte("hello "), eicar...)))
+	if err != nil || r.Clean || r.Threat != "Eicar-Test-Signature" {
+		t.Fatal("eicar should be found:", r, err)
+	}
+
+	dir, _ := ioutil.TempDir("", "irisx")
+	defer os.RemoveAll(dir)
+	ul, err := net.Listen("unix", filepath.Join(dir, "clamd.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ul.Close()
+	go fakeClamd(ul)
+	irisx.DefaultFileScanner = irisx.NewClamAV("unix", filepath.Join(dir, "clamd.sock"))
+	defer func() {
+		irisx.DefaultFileScanner = nil
+	}()
+
+	mem := irisx.NewMemoryStorage("")
+	c := newUploadContext("doc", map[string][]byte{"a.txt": eicar})
+	if sr := c.CheckFile("doc").Save(mem, irisx.HashKey("doc")); sr.Error == nil || c.ParamErrors()["doc"] == "" {
+		t.Fatal("infected file should be rejected")
+	}
+	c = newUploadContext("doc", map[string][]byte{"a.txt": []byte("hello")})
+	if sr := c.CheckFile("doc").Save(mem, irisx.HashKey("doc")); sr.Error != nil {
+		t.Fatal("clean file should be saved:", c.ParamErrors())
+	}
+	puts := &putRecorder{MemoryStorage: mem}
+	c = newStreamContext(map[string][]byte{"a.txt": eicar}, nil)
+	c.StreamUpload(puts, irisx.StreamUploadOptions{})
+	if c.ParamErrors()["files[0]"] == "" || len(puts.sizes) != 0 {
+		t.Fatal("infected stream should be rejected before stored:", c.ParamErrors(), puts.sizes)
+	}
+	c = newStreamContext(map[string][]byte{"a.txt": []byte("hello")}, nil)
+	c.StreamUpload(puts, irisx.StreamUploadOptions{})
+	if len(c.ParamErrors()) != 0 || len(puts.sizes) != 1 || puts.sizes[0] != 5 {
+		t.Fatal("clean stream should be stored with its size:", c.ParamErrors(), puts.sizes)
+	}
+	c = newUploadContext("doc", map[string][]byte{"a.txt": eicar})
+	c.CheckFile("doc").File(filepath.Join(dir, "a.txt"))
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) || c.ParamErrors()["doc"] == "" {
+		t.Fatal("infected file should not be copied:", err, c.ParamErrors())
+	}
+
+	//rejected chunked upload is a bad request rather than a server error
+	app := newTestApp()
+	irisx.ChunkUpload(app, "/uploads", mem, irisx.ChunkUploadOptions{TempDir: dir})
+	server := serveTestApp(app)
+	defer server.Close()
+	form := url.Values{"filename": {"a.txt"}, "size": {strconv.Itoa(len(eicar))}}
+	res := doRequest(t, "POST", server.URL+"/uploads", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, strings.NewReader(form.Encode()))
+	var state irisx.ChunkUploadState
+	json.Unmarshal(res.Data, &state)
+	res = doRequest(t, "PATCH", server.URL+"/uploads/"+state.Id, map[string]string{irisx.HeaderUploadOffset: "0"}, bytes.NewReader(eicar))
+	if res.State != irisx.ChunkStateBadRequest || res.Status != http.StatusBadRequest {
+		t.Fatal("infected chunked upload should be a bad request, got:", res.State, res.Status, string(res.Data))
+	}
+
+	irisx.DefaultFileScanner = irisx.NewClamAV("unix", filepath.Join(dir, "none.sock"))
+	c = newUploadContext("doc", map[string][]byte{"a.txt": []byte("hello")})
+	if sr := c.CheckFile("doc").Save(mem, irisx.HashKey("doc")); sr.Error == nil {
+		t.Fatal("fail closed should reject file when scanner is down")
+	}
+	irisx.ScanFailOpen = true
+	defer func() {
+		irisx.ScanFailOpen = false
+	}()
+	c = newUploadContext("doc", map[string][]byte{"a.txt": []byte("hello")})
+	if sr := c.CheckFile("doc").Save(mem, irisx.HashKey("doc")); sr.Error != nil {
+		t.Fatal("fail open should accept file when scanner is down")
+	}
+}
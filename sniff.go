@@ -0,0 +1,163 @@
+package irisx
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+//sniffLen bytes used to detect content type, same as http.DetectContentType
+const sniffLen = 512
+
+type magicNumber struct {
+	offset      int
+	magic       []byte
+	contentType string
+}
+
+//magicNumbers formats which http.DetectContentType can not detect
+var magicNumbers = []magicNumber{
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftypheix"), "image/heic"},
+	{4, []byte("ftypmif1"), "image/heif"},
+	{4, []byte("ftypavif"), "image/avif"},
+	{4, []byte("ftypqt"), "video/quicktime"},
+	{0, []byte("7z\xBC\xAF\x27\x1C"), "application/x-7z-compressed"},
+	{0, []byte("\xFD7zXZ\x00"), "application/x-xz"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"), "application/x-ole-storage"},
+}
+
+//extContentTypes ext => acceptable sniffed content types
+var extContentTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".bmp":  {"image/bmp"},
+	".webp": {"image/webp"},
+	".ico":  {"image/x-icon"},
+	".tif":  {"image/tiff"},
+	".tiff": {"image/tiff"},
+	".heic": {"image/heic", "image/heif"},
+	".heif": {"image/heic", "image/heif"},
+	".avif": {"image/avif"},
+	".pdf":  {"application/pdf"},
+	".zip":  {"application/zip"},
+	".gz":   {"application/x-gzip"},
+	".tgz":  {"application/x-gzip"},
+	".rar":  {"application/x-rar-compressed"},
+	".7z":   {"application/x-7z-compressed"},
+	".xz":   {"application/x-xz"},
+	".bz2":  {"application/x-bzip2"},
+	".docx": {"application/zip"},
+	".xlsx": {"application/zip"},
+	".pptx": {"application/zip"},
+	".doc":  {"application/x-ole-storage"},
+	".xls":  {"application/x-ole-storage"},
+	".ppt":  {"application/x-ole-storage"},
+	".mp4":  {"video/mp4"},
+	".m4v":  {"video/mp4"},
+	".mov":  {"video/quicktime", "video/mp4"},
+	".webm": {"video/webm"},
+	".avi":  {"video/avi"},
+	".mp3":  {"audio/mpeg"},
+	".wav":  {"audio/wave"},
+	".ogg":  {"application/ogg"},
+	".flac": {"audio/flac"},
+}
+
+//RegisterExtContentTypes register acceptable sniffed content types of ext, eg: RegisterExtContentTypes(".apk","application/zip")
+func RegisterExtContentTypes(ext string, contentTypes ...string) {
+	extContentTypes[strings.ToLower(ext)] = contentTypes
+}
+
+//DetectContentType detect content type by http.DetectContentType and magic numbers, without parameters.
+func DetectContentType(data []byte) string {
+	for _, m := range magicNumbers {
+		if len(data) >= m.offset+len(m.magic) && bytes.Equal(data[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.contentType
+		}
+	}
+	ct := http.DetectContentType(data)
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return ct
+}
+
+//isGenericContentType content type which can not be trusted to identify a format
+func isGenericContentType(ct string) bool {
+	return ct == "application/octet-stream" || ct == "text/plain"
+}
+
+//matchContentType pattern can be "image/png" or "image/*"
+func matchContentType(pattern, ct string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(ct, pattern[:len(pattern)-1])
+	}
+	return pattern == ct
+}
+
+//sniff read the head of file to detect content type, and rewind the file for later reading.
+func (v *ValidatorFile) sniff() string {
+	if v.contentType != "" || v.file == nil {
+		return v.contentType
+	}
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(v.file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		log.Error().Func("sniff").Err(err).Stack().Str("key", v.key).Msg(err.Error())
+		return ""
+	}
+	if _, err := v.file.Seek(0, io.SeekStart); err != nil {
+		log.Error().Func("sniff").Err(err).Stack().Str("key", v.key).Msg(err.Error())
+		return ""
+	}
+	v.contentType = DetectContentType(buf[:n])
+	return v.contentType
+}
+
+//ContentType content type sniffed from the file content, not the one sent by client.
+func (v *ValidatorFile) ContentType() string {
+	return v.sniff()
+}
+
+//ContentTypeIn types: {"image/png","image/*"}
+func (v *ValidatorFile) ContentTypeIn(types []string, msg ...string) *ValidatorFile {
+	if v.goon && len(types) > 0 {
+		ct := v.sniff()
+		for _, x := range types {
+			if matchContentType(x, ct) {
+				return v
+			}
+		}
+		v.addError(v.format(v.key+" is bad file type.", msg))
+	}
+	return v
+}
+
+//ExtMatchContent reject file whose ext does not match its content, eg: a.png with jpeg content.
+func (v *ValidatorFile) ExtMatchContent(msg ...string) *ValidatorFile {
+	if v.goon {
+		ext := strings.ToLower(filepath.Ext(v.header.Filename))
+		types, ok := extContentTypes[ext]
+		if !ok {
+			return v
+		}
+		ct := v.sniff()
+		for _, x := range types {
+			if matchContentType(x, ct) {
+				return v
+			}
+		}
+		v.addError(v.format(v.key+" is bad file type.", msg))
+	}
+	return v
+}
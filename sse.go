@@ -0,0 +1,149 @@
+package irisx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RocksonZeta/wrap/errs"
+)
+
+//SSEHeartbeat interval of heartbeat comments which keep proxies from closing idle streams, 0 means no heartbeat
+var SSEHeartbeat = 15 * time.Second
+
+//SSERetry reconnection delay sent to clients, 0 means the browser default
+var SSERetry time.Duration
+
+//ErrClientGone the client has disconnected
+var ErrClientGone = errors.New("client disconnected")
+
+//SSESend send one event: empty event means "message", empty id keeps the last id of client
+type SSESend = func(event, id string, data interface{}) error
+
+//LastEventId id of the last event received by the reconnecting client
+func (ctx *Context) LastEventId() string {
+	if id := ctx.GetHeader("Last-Event-ID"); id != "" {
+		return id
+	}
+	//EventSource polyfills can not set headers
+	return ctx.URLParam("lastEventId")
+}
+
+//SSE stream server-sent events until producer returns or client disconnects.
+//data is sent as the Ok envelope in the format negotiated by Accept, eg: "text/event-stream, application/xml",
+//binary formats are base64 encoded, formats of registered encoders fallback to json. send returns ErrClientGone after the client has disconnected,
+//long running producers should also watch ctx.Request().Context().Done().
+func (ctx *Context) SSE(producer func(send SSESend) error) error {
+	flusher, ok := ctx.ResponseWriter().Flusher()
+	if !ok {
+		err := errors.New("response writer does not support flush")
+		log.Error().Func("SSE").Err(err).Stack().Msg(err.Error())
+		return err
+	}
+	header := ctx.ResponseWriter().Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	//disable buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	e := negotiateEncoder(ctx.GetHeader("Accept"))
+	if e.marshal == nil {
+		e = responseEncoders[0]
+	}
+	done := ctx.Request().Context().Done()
+	var mu sync.Mutex
+	write := func(bs []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case <-done:
+			return ErrClientGone
+		default:
+		}
+		if _, err := ctx.Write(bs); err != nil {
+			return ErrClientGone
+		}
+		flusher.Flush()
+		return nil
+	}
+	open := ":ok\n\n"
+	if SSERetry > 0 {
+		open = "retry: " + strconv.FormatInt(int64(SSERetry/time.Millisecond), 10) + "\n\n"
+	}
+	if err := write([]byte(open)); err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if SSEHeartbeat > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(SSEHeartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if write([]byte(":\n\n")) != nil {
+						return
+					}
+				case <-done:
+					return
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	send := func(event, id string, data interface{}) error {
+		bs, err := encodeSSEEvent(e, event, id, data)
+		if err != nil {
+			log.Error().Func("SSE").Err(err).Stack().Str("event", event).Msg(err.Error())
+			return err
+		}
+		return write(bs)
+	}
+	err := producer(send)
+	close(stop)
+	wg.Wait()
+	if err != nil && err != ErrClientGone {
+		log.Error().Func("SSE").Err(err).Stack().Str("path", ctx.Path()).Msg(err.Error())
+	}
+	return err
+}
+
+//encodeSSEEvent event: {event}\nid: {id}\ndata: {Ok envelope}\n\n
+func encodeSSEEvent(e responseEncoder, event, id string, data interface{}) ([]byte, error) {
+	bs, err := e.marshal(errs.Err{State: 0, Data: data}.Result())
+	if err != nil {
+		return nil, err
+	}
+	if !isTextContentType(e.contentType) {
+		bs = []byte(base64.StdEncoding.EncodeToString(bs))
+	}
+	buf := &bytes.Buffer{}
+	if event != "" {
+		buf.WriteString("event: " + sseField(event) + "\n")
+	}
+	if id != "" {
+		buf.WriteString("id: " + sseField(id) + "\n")
+	}
+	for _, line := range strings.Split(string(bs), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func isTextContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || strings.HasSuffix(contentType, "json") || strings.HasSuffix(contentType, "xml")
+}
+
+//sseField line breaks would end the field
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "", "\x00", "").Replace(s)
+}
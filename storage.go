@@ -0,0 +1,318 @@
+package irisx
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Storage upload storage backend
+type Storage interface {
+	//Put save r as key, size may be -1 if unknown
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	//Url public url of key
+	Url(key string) string
+}
+
+var ErrBadStorageKey = errors.New("bad storage key")
+var ErrStorageKeyNotFound = errors.New("storage key not found")
+var ErrFileRejected = errors.New("file rejected by scanner")
+
+//KeyFunc generate storage key for upload file, hash is hex sha256 of the file content.
+type KeyFunc func(file UploadFile, hash string) string
+
+var safeExtReg = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+//SafeExt lower case ext, empty if ext has unsafe chars
+func SafeExt(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !safeExtReg.MatchString(ext) {
+		return ""
+	}
+	return ext
+}
+
+//CleanKey clean key and reject keys escaping the storage root
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.Contains(key, "\x00") || strings.Contains(key, "\\") {
+		return "", ErrBadStorageKey
+	}
+	k := path.Clean("/" + key)[1:]
+	if k == "" || k != strings.TrimPrefix(key, "/") {
+		return "", ErrBadStorageKey
+	}
+	return k, nil
+}
+
+//RandomName random hex string of n bytes
+func RandomName(n int) string {
+	bs := make([]byte, n)
+	if _, err := rand.Read(bs); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(bs)
+}
+
+//HashKey content addressed key: prefix/ab/cd/abcd...ext, same content same key. random name is used if hash is unknown.
+func HashKey(prefix string) KeyFunc {
+	return func(file UploadFile, hash string) string {
+		if len(hash) < 4 {
+			hash = RandomName(32)
+		}
+		return path.Join(prefix, hash[:2], hash[2:4], hash+SafeExt(file.Ext))
+	}
+}
+
+//DateKey key: prefix/20060102/random.ext
+func DateKey(prefix string) KeyFunc {
+	return func(file UploadFile, hash string) string {
+		return path.Join(prefix, time.Now().Format("20060102"), RandomName(16)+SafeExt(file.Ext))
+	}
+}
+
+//writeFileAtomic write r to a temp file in the same dir, then rename it to dstFile
+func writeFileAtomic(dstFile string, r io.Reader) error {
+	dir := filepath.Dir(dstFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(dstFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dstFile)
+}
+
+//LocalStorage save files under Dir, Url is BaseUrl+"/"+key
+type LocalStorage struct {
+	Dir     string
+	BaseUrl string
+}
+
+func NewLocalStorage(dir, baseUrl string) *LocalStorage {
+	return &LocalStorage{Dir: dir, BaseUrl: strings.TrimRight(baseUrl, "/")}
+}
+
+func (s *LocalStorage) file(key string) (string, error) {
+	k, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(k)), nil
+}
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	f, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f, r)
+}
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	f, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(f)
+	if os.IsNotExist(err) {
+		return nil, ErrStorageKeyNotFound
+	}
+	return r, err
+}
+func (s *LocalStorage) Delete(key string) error {
+	f, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(f)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+func (s *LocalStorage) Url(key string) string {
+	return s.BaseUrl + "/" + strings.TrimPrefix(key, "/")
+}
+
+//MemoryStorage keep files in memory, for tests
+type MemoryStorage struct {
+	BaseUrl string
+	lock    sync.RWMutex
+	files   map[string][]byte
+	types   map[string]string
+}
+
+func NewMemoryStorage(baseUrl string) *MemoryStorage {
+	return &MemoryStorage{BaseUrl: strings.TrimRight(baseUrl, "/"), files: make(map[string][]byte), types: make(map[string]string)}
+}
+func (s *MemoryStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	k, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.files[k] = bs
+	s.types[k] = contentType
+	return nil
+}
+func (s *MemoryStorage) Get(key string) (io.ReadCloser, error) {
+	k, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	bs, ok := s.files[k]
+	if !ok {
+		return nil, ErrStorageKeyNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(bs)), nil
+}
+func (s *MemoryStorage) Delete(key string) error {
+	k, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.files, k)
+	delete(s.types, k)
+	return nil
+}
+func (s *MemoryStorage) Url(key string) string {
+	return s.BaseUrl + "/" + strings.TrimPrefix(key, "/")
+}
+
+//ContentType content type of key when put
+func (s *MemoryStorage) ContentType(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.types[strings.TrimPrefix(key, "/")]
+}
+
+//Keys all keys
+func (s *MemoryStorage) Keys() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	r := make([]string, 0, len(s.files))
+	for k := range s.files {
+		r = append(r, k)
+	}
+	return r
+}
+
+type SaveFileResult struct {
+	Key         string
+	Url         string
+	Hash        string
+	Size        int64
+	ContentType string
+	Error       error
+}
+
+//hash sha256 of file content, and rewind the file
+func (v *ValidatorFile) hash() (string, error) {
+	if v.contentHash != "" {
+		return v.contentHash, nil
+	}
+	if v.file == nil {
+		return "", os.ErrClosed
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, v.file); err != nil {
+		return "", err
+	}
+	if _, err := v.file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	v.contentHash = hex.EncodeToString(h.Sum(nil))
+	return v.contentHash, nil
+}
+
+//Hash hex sha256 of file content
+func (v *ValidatorFile) Hash() string {
+	h, err := v.hash()
+	if err != nil {
+		log.Error().Func("Hash").Err(err).Stack().Str("key", v.key).Msg(err.Error())
+	}
+	return h
+}
+
+//saveErr why the file can not be saved: error of reading the form file, ErrValidation if validation failed, or os.ErrClosed
+func (v *ValidatorFile) saveErr() error {
+	if v.err != nil {
+		return v.err
+	}
+	if !v.goon {
+		return ErrValidation
+	}
+	return os.ErrClosed
+}
+
+//Save save file to storage if no error occurred and close the file, eg: v.Save(storage,irisx.HashKey("avatar")).Url
+func (v *ValidatorFile) Save(storage Storage, keyFunc KeyFunc) SaveFileResult {
+	defer v.Close()
+	var r SaveFileResult
+	if !v.goon || v.file == nil {
+		r.Error = v.saveErr()
+		return r
+	}
+	if !v.scanDefault() {
+		r.Error = ErrFileRejected
+		return r
+	}
+	var err error
+	if r.Hash, err = v.hash(); err != nil {
+		log.Error().Func("Save").Err(err).Stack().Str("key", v.key).Msg(err.Error())
+		v.addError(v.key + ":" + err.Error())
+		r.Error = err
+		return r
+	}
+	r.Key = keyFunc(v.File(""), r.Hash)
+	r.Size = v.header.Size
+	r.ContentType = v.sniff()
+	err = storage.Put(r.Key, v.file, r.Size, r.ContentType)
+	if _, serr := v.file.Seek(0, io.SeekStart); err == nil {
+		err = serr
+	}
+	if err != nil {
+		log.Error().Func("Save").Err(err).Stack().Str("key", v.key).Str("storageKey", r.Key).Msg(err.Error())
+		v.addError(v.key + ":" + err.Error())
+		r.Error = err
+		return r
+	}
+	r.Url = storage.Url(r.Key)
+	return r
+}
@@ -0,0 +1,336 @@
+package irisx
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//S3Storage S3 compatible object storage, eg: aws s3, minio, oss
+type S3Storage struct {
+	//Endpoint eg: https://s3.us-east-1.amazonaws.com , http://127.0.0.1:9000
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	//PathStyle endpoint/bucket/key, otherwise bucket.endpoint/key
+	PathStyle bool
+	//BaseUrl public url prefix, default is the object url
+	BaseUrl string
+	Client  *http.Client
+	//PartSize part size of multipart upload when content length is unknown, default 8MB, s3 requires at least 5MB
+	PartSize int64
+}
+
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey string) *S3Storage {
+	return &S3Storage{Endpoint: strings.TrimRight(endpoint, "/"), Region: region, Bucket: bucket, AccessKey: accessKey, SecretKey: secretKey, PathStyle: true}
+}
+
+type S3Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *S3Error) Error() string {
+	return "s3: status " + http.StatusText(e.StatusCode) + ": " + e.Body
+}
+
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+func (s *S3Storage) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+//objectUrl url of key
+func (s *S3Storage) objectUrl(key string) (*url.URL, error) {
+	k, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/" + k
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + k
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return u, nil
+}
+
+func (s *S3Storage) partSize() int64 {
+	if s.PartSize > 0 {
+		return s.PartSize
+	}
+	return 8 << 20
+}
+
+//do request key with query, eg: uploads , partNumber=1&uploadId=x
+func (s *S3Storage) do(method, key, query string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	u, err := s.objectUrl(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && size >= 0 {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.Sign(req, time.Now().UTC())
+	res, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		bs, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+		res.Body.Close()
+		if res.StatusCode == http.StatusNotFound {
+			return nil, ErrStorageKeyNotFound
+		}
+		return nil, &S3Error{StatusCode: res.StatusCode, Body: string(bs)}
+	}
+	return res, nil
+}
+
+//Put size < 0 means unknown, r will be uploaded by multipart upload
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		return s.putMultipart(key, r, contentType)
+	}
+	res, err := s.do(http.MethodPut, key, "", r, size, contentType)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+type s3CompleteUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []s3Part `xml:"Part"`
+}
+
+//putMultipart upload r part by part, r smaller than a part is uploaded by a single put
+func (s *S3Storage) putMultipart(key string, r io.Reader, contentType string) error {
+	buf := make([]byte, s.partSize())
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.Put(key, bytes.NewReader(buf[:n]), int64(n), contentType)
+	}
+	if err != nil {
+		return err
+	}
+	res, err := s.do(http.MethodPost, key, "uploads", nil, 0, contentType)
+	if err != nil {
+		return err
+	}
+	var initiate struct{ UploadId string }
+	err = xml.NewDecoder(res.Body).Decode(&initiate)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	if initiate.UploadId == "" {
+		return errors.New("s3: no upload id")
+	}
+	uploadId := s3EscapeQuery(initiate.UploadId)
+	var complete s3CompleteUpload
+	last := false
+	for {
+		partNumber := len(complete.Parts) + 1
+		res, err := s.do(http.MethodPut, key, "partNumber="+strconv.Itoa(partNumber)+"&uploadId="+uploadId, bytes.NewReader(buf[:n]), int64(n), "")
+		if err != nil {
+			s.abortMultipart(key, uploadId)
+			return err
+		}
+		res.Body.Close()
+		complete.Parts = append(complete.Parts, s3Part{PartNumber: partNumber, ETag: res.Header.Get("ETag")})
+		if last {
+			break
+		}
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		last = err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			s.abortMultipart(key, uploadId)
+			return err
+		}
+	}
+	bs, err := xml.Marshal(complete)
+	if err != nil {
+		s.abortMultipart(key, uploadId)
+		return err
+	}
+	res, err = s.do(http.MethodPost, key, "uploadId="+uploadId, bytes.NewReader(bs), int64(len(bs)), "application/xml")
+	if err != nil {
+		s.abortMultipart(key, uploadId)
+		return err
+	}
+	defer res.Body.Close()
+	//s3 may report errors of complete in a 200 response
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(body, []byte("<Error>")) {
+		s.abortMultipart(key, uploadId)
+		return &S3Error{StatusCode: res.StatusCode, Body: string(body)}
+	}
+	return nil
+}
+
+func (s *S3Storage) abortMultipart(key, uploadId string) {
+	res, err := s.do(http.MethodDelete, key, "uploadId="+uploadId, nil, 0, "")
+	if err != nil {
+		log.Error().Func("abortMultipart").Err(err).Str("key", key).Msg(err.Error())
+		return
+	}
+	res.Body.Close()
+}
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	res, err := s.do(http.MethodGet, key, "", nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+func (s *S3Storage) Delete(key string) error {
+	res, err := s.do(http.MethodDelete, key, "", nil, 0, "")
+	if err == ErrStorageKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+func (s *S3Storage) Url(key string) string {
+	if s.BaseUrl != "" {
+		return strings.TrimRight(s.BaseUrl, "/") + "/" + strings.TrimPrefix(key, "/")
+	}
+	u, err := s.objectUrl(key)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+//Sign sign request with aws signature v4, X-Amz-Content-Sha256 is UNSIGNED-PAYLOAD if it is not set
+func (s *S3Storage) Sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = s3UnsignedPayload
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSha256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSha256(key, s.Region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(bs []byte) string {
+	h := sha256.Sum256(bs)
+	return hex.EncodeToString(h[:])
+}
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+//s3EscapePath uri encode every byte except unreserved chars and '/'
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+//s3EscapeQuery uri encode every byte except unreserved chars, url.QueryEscape is not RFC 3986, eg: space is +
+func s3EscapeQuery(p string) string {
+	return s3Escape(p, false)
+}
+
+//s3CanonicalQuery encoded params sorted by key then value
+func s3CanonicalQuery(q url.Values) string {
+	params := make([]string, 0, len(q))
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, s3EscapeQuery(k)+"="+s3EscapeQuery(v))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func s3Escape(p string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && keepSlash {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
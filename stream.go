@@ -0,0 +1,240 @@
+package irisx
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var errStreamTooLarge = errors.New("stream too large")
+
+type StreamUploadOptions struct {
+	//MaxFileSize max size of every file, <= 0 means no limit
+	MaxFileSize int64
+	//MaxTotalSize max size of all files, <= 0 means no limit
+	MaxTotalSize int64
+	//MaxFiles max count of files, <= 0 means no limit
+	MaxFiles int
+	//MaxValueSize max size of every non-file value, default 1M
+	MaxValueSize int64
+	//Fields accepted file fields, empty means all
+	Fields []string
+	//ExtIn accepted exts, eg: {"jpg","png"}
+	ExtIn []string
+	//ContentTypeIn accepted sniffed content types, eg: {"image/*"}
+	ContentTypeIn []string
+	//KeyFunc storage key, hash is empty because the file is not read yet, default DateKey("")
+	KeyFunc KeyFunc
+}
+
+//StreamFile file saved by StreamUpload
+type StreamFile struct {
+	SaveFileResult
+	Field    string
+	Filename string
+}
+
+type StreamUploadResult struct {
+	Values url.Values
+	Files  []StreamFile
+}
+
+//limitHashReader fail when read more than limit bytes, and compute hash on the fly
+type limitHashReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	hash  hash.Hash
+}
+
+func (l *limitHashReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	l.hash.Write(p[:n])
+	if l.limit > 0 && l.n > l.limit {
+		return n, errStreamTooLarge
+	}
+	return n, err
+}
+
+//StreamUpload read multipart body part by part and pipe files to storage without buffering the whole form,
+//errors are reported by AddParamError, keyed by field or field[i] if the field has many files.
+func (ctx *Context) StreamUpload(storage Storage, opts StreamUploadOptions) *StreamUploadResult {
+	r := &StreamUploadResult{Values: url.Values{}}
+	if opts.MaxValueSize <= 0 {
+		opts.MaxValueSize = 1 << 20
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = DateKey("")
+	}
+	reader, err := ctx.Request().MultipartReader()
+	if err != nil {
+		log.Error().Func("StreamUpload").Err(err).Stack().Msg(err.Error())
+		ctx.AddParamError("body", "body should be multipart form.")
+		return r
+	}
+	var total int64
+	counts := make(map[string]int)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Error().Func("StreamUpload").Err(err).Stack().Msg(err.Error())
+			ctx.AddParamError("body", "body is bad format.")
+			return r
+		}
+		field := part.FormName()
+		if part.FileName() == "" {
+			bs, err := ioutil.ReadAll(io.LimitReader(part, opts.MaxValueSize+1))
+			part.Close()
+			if err != nil || int64(len(bs)) > opts.MaxValueSize {
+				ctx.AddParamError(field, field+"'s length must equal or less than "+strconv.FormatInt(opts.MaxValueSize, 10))
+				continue
+			}
+			r.Values.Add(field, string(bs))
+			continue
+		}
+		key := field
+		if opts.MaxFiles != 1 {
+			key = field + "[" + strconv.Itoa(counts[field]) + "]"
+		}
+		counts[field]++
+		if len(opts.Fields) > 0 && !stringIn(field, opts.Fields) {
+			part.Close()
+			continue
+		}
+		if opts.MaxFiles > 0 && len(r.Files) >= opts.MaxFiles {
+			part.Close()
+			ctx.AddParamError(field, field+"'s count must equal or less than "+strconv.Itoa(opts.MaxFiles))
+			continue
+		}
+		limit := opts.MaxFileSize
+		if opts.MaxTotalSize > 0 && (limit <= 0 || opts.MaxTotalSize-total < limit) {
+			limit = opts.MaxTotalSize - total
+			//limit 0 means no limit for limitHashReader
+			if limit <= 0 {
+				part.Close()
+				ctx.AddParamError(key, "total length of files must equal or less than "+strconv.FormatInt(opts.MaxTotalSize, 10))
+				continue
+			}
+		}
+		f := ctx.streamFile(storage, opts, part, key, limit)
+		part.Close()
+		if f == nil {
+			continue
+		}
+		total += f.Size
+		r.Files = append(r.Files, *f)
+	}
+	return r
+}
+
+func stringIn(s string, ss []string) bool {
+	for _, x := range ss {
+		if s == x {
+			return true
+		}
+	}
+	return false
+}
+
+//streamFile check and save one part
+func (ctx *Context) streamFile(storage Storage, opts StreamUploadOptions, part *multipart.Part, key string, limit int64) *StreamFile {
+	f := &StreamFile{Field: part.FormName(), Filename: part.FileName()}
+	ext := strings.ToLower(strings.TrimPrefix(SafeExt(f.Filename), "."))
+	if len(opts.ExtIn) > 0 && !stringIn(ext, opts.ExtIn) {
+		ctx.AddParamError(key, key+" is bad file type.")
+		return nil
+	}
+	br := bufio.NewReaderSize(part, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		log.Error().Func("StreamUpload").Err(err).Stack().Str("key", key).Msg(err.Error())
+		ctx.AddParamError(key, key+":"+err.Error())
+		return nil
+	}
+	f.ContentType = DetectContentType(head)
+	if len(opts.ContentTypeIn) > 0 && !contentTypeIn(f.ContentType, opts.ContentTypeIn) {
+		ctx.AddParamError(key, key+" is bad file type.")
+		return nil
+	}
+	if types, ok := extContentTypes["."+ext]; ok && !contentTypeIn(f.ContentType, types) {
+		ctx.AddParamError(key, key+" is bad file type.")
+		return nil
+	}
+	lr := &limitHashReader{r: br, limit: limit, hash: sha256.New()}
+	f.Key = opts.KeyFunc(UploadFile{Header: &multipart.FileHeader{Filename: f.Filename, Header: part.Header}, Ext: "." + ext, Size: -1}, "")
+	fail := func(err error) *StreamFile {
+		storage.Delete(f.Key)
+		if errors.Is(err, errStreamTooLarge) {
+			ctx.AddParamError(key, key+"'s length must equal or less than "+strconv.FormatInt(limit, 10))
+			return nil
+		}
+		log.Error().Func("StreamUpload").Err(err).Stack().Str("key", key).Str("storageKey", f.Key).Msg(err.Error())
+		ctx.AddParamError(key, key+":"+err.Error())
+		return nil
+	}
+	var body io.Reader = lr
+	size := int64(-1)
+	if DefaultFileScanner != nil {
+		//files must pass the scanner before stored, so spool the file and scan it first
+		spool, err := spoolFile(lr)
+		if spool != nil {
+			defer func() {
+				spool.Close()
+				os.Remove(spool.Name())
+			}()
+		}
+		if err != nil {
+			return fail(err)
+		}
+		result, err := DefaultFileScanner.Scan(spool)
+		if _, serr := spool.Seek(0, io.SeekStart); err == nil {
+			err = serr
+		}
+		if !ctx.checkScan(key, result, err, nil) {
+			return nil
+		}
+		body, size = spool, lr.n
+	}
+	if err := storage.Put(f.Key, body, size, f.ContentType); err != nil {
+		return fail(err)
+	}
+	f.Size = lr.n
+	f.Hash = hex.EncodeToString(lr.hash.Sum(nil))
+	f.Url = storage.Url(f.Key)
+	return f
+}
+
+//spoolFile copy r to a temp file and rewind it, the file should be removed by caller
+func spoolFile(r io.Reader) (*os.File, error) {
+	f, err := ioutil.TempFile("", "irisx-spool")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		return f, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return f, err
+}
+
+func contentTypeIn(ct string, types []string) bool {
+	for _, x := range types {
+		if matchContentType(x, ct) {
+			return true
+		}
+	}
+	return false
+}
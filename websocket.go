@@ -0,0 +1,426 @@
+package irisx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kataras/iris/v12"
+)
+
+var (
+	ErrWsHubClosed  = errors.New("websocket hub closed")
+	ErrWsConnClosed = errors.New("websocket connection closed")
+	//ErrWsQueueFull the client is too slow to consume messages, the connection will be closed
+	ErrWsQueueFull = errors.New("websocket send queue full")
+)
+
+//WsHubOptions options of WsHub, zero values use defaults
+type WsHubOptions struct {
+	//IntUid resolve uid by GetUidInt, default GetUidString
+	IntUid bool
+	//RequireUid reject handshake of anonymous users with 401
+	RequireUid bool
+	//CheckOrigin default same origin only
+	CheckOrigin func(r *http.Request) bool
+	//SendQueue max pending messages of a connection, default 64
+	SendQueue int
+	//PingInterval default 30s
+	PingInterval time.Duration
+	//PongWait connection is dead if no pong in this duration, default 2*PingInterval
+	PongWait time.Duration
+	//WriteWait timeout of writing a message, default 10s
+	WriteWait time.Duration
+	//MaxMessageSize max size of received messages, default 64K
+	MaxMessageSize int64
+	//OnOpen called after the connection is registered, can join rooms here
+	OnOpen func(c *WsConn)
+	//OnMessage called with every message received
+	OnMessage func(c *WsConn, msg []byte)
+	//OnClose called after the connection is unregistered
+	OnClose func(c *WsConn)
+}
+
+//WsHub connections indexed by uid and room
+type WsHub struct {
+	opts     WsHubOptions
+	upgrader websocket.Upgrader
+	lock     sync.RWMutex
+	conns    map[*WsConn]struct{}
+	users    map[string]map[*WsConn]struct{}
+	rooms    map[string]map[*WsConn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+//WsConn a websocket connection of hub
+type WsConn struct {
+	//Id random id of connection
+	Id string
+	//Uid uid of signed in user, empty for anonymous users
+	Uid       string
+	hub       *WsHub
+	ws        *websocket.Conn
+	send      chan []byte
+	rooms     map[string]struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewWsHub(opts WsHubOptions) *WsHub {
+	if opts.SendQueue <= 0 {
+		opts.SendQueue = 64
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+	if opts.PongWait <= opts.PingInterval {
+		opts.PongWait = 2 * opts.PingInterval
+	}
+	if opts.WriteWait <= 0 {
+		opts.WriteWait = 10 * time.Second
+	}
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = 64 << 10
+	}
+	return &WsHub{
+		opts:     opts,
+		upgrader: websocket.Upgrader{CheckOrigin: opts.CheckOrigin},
+		conns:    make(map[*WsConn]struct{}),
+		users:    make(map[string]map[*WsConn]struct{}),
+		rooms:    make(map[string]map[*WsConn]struct{}),
+	}
+}
+
+//Handler iris handler of websocket endpoint, eg: app.Get("/ws", hub.Handler)
+func (h *WsHub) Handler(ictx iris.Context) {
+	ctx := ictx.(*Context)
+	c, err := h.Upgrade(ctx)
+	if err != nil {
+		return
+	}
+	c.Serve()
+}
+
+//Upgrade handshake of ctx, uid is resolved before upgrading. the caller should call Serve of the returned connection.
+func (h *WsHub) Upgrade(ctx *Context) (*WsConn, error) {
+	h.lock.RLock()
+	closed := h.closed
+	h.lock.RUnlock()
+	if closed {
+		ctx.StatusCode(http.StatusServiceUnavailable)
+		return nil, ErrWsHubClosed
+	}
+	uid := h.uidOf(ctx)
+	if uid == "" && h.opts.RequireUid {
+		ctx.StatusCode(http.StatusUnauthorized)
+		return nil, ErrUnauthorized
+	}
+	ws, err := h.upgrader.Upgrade(ctx.ResponseWriter(), ctx.Request(), nil)
+	if err != nil {
+		//upgrader has responded the error
+		log.Warn().Func("Upgrade").Err(err).Str("path", ctx.Path()).Msg(err.Error())
+		return nil, err
+	}
+	c := &WsConn{Id: RandomName(8), Uid: uid, hub: h, ws: ws, send: make(chan []byte, h.opts.SendQueue), rooms: make(map[string]struct{}), done: make(chan struct{})}
+	if !h.register(c) {
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(h.opts.WriteWait))
+		ws.Close()
+		return nil, ErrWsHubClosed
+	}
+	return c, nil
+}
+
+func (h *WsHub) uidOf(ctx *Context) string {
+	if nil == ctx.SessionProvider {
+		return ""
+	}
+	if h.opts.IntUid {
+		if uid := ctx.GetUidInt(); uid != 0 {
+			return strconv.Itoa(uid)
+		}
+		return ""
+	}
+	return ctx.GetUidString()
+}
+
+func (h *WsHub) register(c *WsConn) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		return false
+	}
+	h.conns[c] = struct{}{}
+	if c.Uid != "" {
+		addConn(h.users, c.Uid, c)
+	}
+	h.wg.Add(1)
+	return true
+}
+
+func (h *WsHub) unregister(c *WsConn) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.conns[c]; !ok {
+		return
+	}
+	delete(h.conns, c)
+	if c.Uid != "" {
+		removeConn(h.users, c.Uid, c)
+	}
+	for room := range c.rooms {
+		removeConn(h.rooms, room, c)
+	}
+	h.wg.Done()
+}
+
+func addConn(index map[string]map[*WsConn]struct{}, key string, c *WsConn) {
+	m, ok := index[key]
+	if !ok {
+		m = make(map[*WsConn]struct{})
+		index[key] = m
+	}
+	m[c] = struct{}{}
+}
+
+func removeConn(index map[string]map[*WsConn]struct{}, key string, c *WsConn) {
+	if m, ok := index[key]; ok {
+		delete(m, c)
+		if len(m) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+//snapshot connections of index key, all connections if index is nil
+func (h *WsHub) snapshot(index map[string]map[*WsConn]struct{}, key string) []*WsConn {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	m := h.conns
+	if index != nil {
+		m = index[key]
+	}
+	r := make([]*WsConn, 0, len(m))
+	for c := range m {
+		r = append(r, c)
+	}
+	return r
+}
+
+//deliver msg to conns, return number of queued connections
+func deliver(conns []*WsConn, msg []byte) int {
+	n := 0
+	for _, c := range conns {
+		if c.Send(msg) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+//Broadcast send msg to all connections, return number of connections queued
+func (h *WsHub) Broadcast(msg []byte) int {
+	return deliver(h.snapshot(nil, ""), msg)
+}
+
+//BroadcastRoom send msg to connections in room
+func (h *WsHub) BroadcastRoom(room string, msg []byte) int {
+	return deliver(h.snapshot(h.rooms, room), msg)
+}
+
+//SendToUser send msg to all connections of uid
+func (h *WsHub) SendToUser(uid string, msg []byte) int {
+	return deliver(h.snapshot(h.users, uid), msg)
+}
+
+//SendToUserJSON send v as json to all connections of uid
+func (h *WsHub) SendToUserJSON(uid string, v interface{}) (int, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Func("SendToUserJSON").Err(err).Stack().Msg(err.Error())
+		return 0, err
+	}
+	return h.SendToUser(uid, bs), nil
+}
+
+//Online user has connections or not
+func (h *WsHub) Online(uid string) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.users[uid]) > 0
+}
+
+//Count number of connections
+func (h *WsHub) Count() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.conns)
+}
+
+//Shutdown stop accepting connections, close all connections with going away and wait them until ctx done
+func (h *WsHub) Shutdown(ctx context.Context) error {
+	h.lock.Lock()
+	h.closed = true
+	h.lock.Unlock()
+	for _, c := range h.snapshot(nil, "") {
+		c.Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//Send queue msg, the connection will be closed if its queue is full
+func (c *WsConn) Send(msg []byte) error {
+	select {
+	case <-c.done:
+		return ErrWsConnClosed
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return nil
+	default:
+		log.Warn().Func("Send").Str("id", c.Id).Str("uid", c.Uid).Int("queue", cap(c.send)).Msg(ErrWsQueueFull.Error())
+		c.Close()
+		return ErrWsQueueFull
+	}
+}
+
+//SendJSON queue v as json
+func (c *WsConn) SendJSON(v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Func("SendJSON").Err(err).Stack().Msg(err.Error())
+		return err
+	}
+	return c.Send(bs)
+}
+
+//Join room
+func (c *WsConn) Join(room string) {
+	c.hub.lock.Lock()
+	defer c.hub.lock.Unlock()
+	if _, ok := c.hub.conns[c]; !ok {
+		return
+	}
+	c.rooms[room] = struct{}{}
+	addConn(c.hub.rooms, room, c)
+}
+
+//Leave room
+func (c *WsConn) Leave(room string) {
+	c.hub.lock.Lock()
+	defer c.hub.lock.Unlock()
+	delete(c.rooms, room)
+	removeConn(c.hub.rooms, room, c)
+}
+
+//Rooms joined by the connection
+func (c *WsConn) Rooms() []string {
+	c.hub.lock.RLock()
+	defer c.hub.lock.RUnlock()
+	r := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		r = append(r, room)
+	}
+	return r
+}
+
+//Close the connection after pending messages are written
+func (c *WsConn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+//Serve pump messages until the connection closed
+func (c *WsConn) Serve() {
+	if nil != c.hub.opts.OnOpen {
+		c.hub.opts.OnOpen(c)
+	}
+	go c.writePump()
+	c.readPump()
+}
+
+func (c *WsConn) readPump() {
+	opts := c.hub.opts
+	defer c.Close()
+	c.ws.SetReadLimit(opts.MaxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(opts.PongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(opts.PongWait))
+	})
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+				log.Warn().Func("readPump").Err(err).Str("id", c.Id).Str("uid", c.Uid).Msg(err.Error())
+			}
+			return
+		}
+		if nil != opts.OnMessage {
+			opts.OnMessage(c, msg)
+		}
+	}
+}
+
+func (c *WsConn) writePump() {
+	opts := c.hub.opts
+	ticker := time.NewTicker(opts.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+		c.hub.unregister(c)
+		if nil != opts.OnClose {
+			opts.OnClose(c)
+		}
+	}()
+	for {
+		select {
+		case msg := <-c.send:
+			if c.write(websocket.TextMessage, msg) != nil {
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			if c.write(websocket.PingMessage, nil) != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			//flush pending messages then say goodbye
+		flush:
+			for {
+				select {
+				case msg := <-c.send:
+					if c.write(websocket.TextMessage, msg) != nil {
+						return
+					}
+				default:
+					break flush
+				}
+			}
+			c.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+			return
+		}
+	}
+}
+
+func (c *WsConn) write(messageType int, data []byte) error {
+	c.ws.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteWait))
+	return c.ws.WriteMessage(messageType, data)
+}
@@ -0,0 +1,155 @@
+package irisx_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RocksonZeta/irisx"
+	"github.com/gorilla/websocket"
+	"github.com/kataras/iris/v12"
+	irisctx "github.com/kataras/iris/v12/context"
+)
+
+func dialWs(t *testing.T, url, sid string) *websocket.Conn {
+	header := http.Header{}
+	if sid != "" {
+		header.Set("Cookie", "token1="+sid)
+	}
+	ws, res, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal("dial:", err, res)
+	}
+	return ws
+}
+
+func readWs(t *testing.T, ws *websocket.Conn) string {
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal("read:", err)
+	}
+	return string(msg)
+}
+
+func waitFor(t *testing.T, msg string, ok func() bool) {
+	for i := 0; i < 200; i++ {
+		if ok() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+//go test -run TestWsHub -v
+func TestWsHub(t *testing.T) {
+	sessionValues["ws1/uid"] = "tom"
+	sessionValues["ws2/uid"] = "jerry"
+	defer func() {
+		delete(sessionValues, "ws1/uid")
+		delete(sessionValues, "ws2/uid")
+	}()
+	hub := irisx.NewWsHub(irisx.WsHubOptions{
+		RequireUid: true,
+		OnOpen: func(c *irisx.WsConn) {
+			c.Join("lobby")
+		},
+		OnMessage: func(c *irisx.WsConn, msg []byte) {
+			c.Send([]byte(c.Uid + ":" + strings.ToUpper(string(msg))))
+		},
+	})
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.ContextPool.Attach(func() irisctx.Context {
+		return &irisx.Context{Context: irisctx.NewContext(app), SessionProvider: &Sessions{}}
+	})
+	app.Get("/ws", hub.Handler)
+	server := serveTestApp(app)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	if _, res, err := websocket.DefaultDialer.Dial(url, nil); err == nil || res.StatusCode != 401 {
+		t.Fatal("anonymous should be rejected:", err)
+	}
+	tom := dialWs(t, url, "ws1")
+	defer tom.Close()
+	jerry := dialWs(t, url, "ws2")
+	defer jerry.Close()
+	waitFor(t, "both should be online", func() bool {
+		return hub.Online("tom") && hub.Online("jerry")
+	})
+
+	tom.WriteMessage(websocket.TextMessage, []byte("hi"))
+	if msg := readWs(t, tom); msg != "tom:HI" {
+		t.Fatal("echo not match:", msg)
+	}
+	if n := hub.SendToUser("jerry", []byte("only jerry")); n != 1 {
+		t.Fatal("should send to 1 connection:", n)
+	}
+	if msg := readWs(t, jerry); msg != "only jerry" {
+		t.Fatal("send to user not match:", msg)
+	}
+	if n := hub.BroadcastRoom("lobby", []byte("lobby")); n != 2 {
+		t.Fatal("room should have 2 connections:", n)
+	}
+	if readWs(t, tom) != "lobby" || readWs(t, jerry) != "lobby" {
+		t.Fatal("room broadcast not received")
+	}
+	if n := hub.BroadcastRoom("none", []byte("x")); n != 0 {
+		t.Fatal("unknown room should have no connections:", n)
+	}
+
+	jerry.Close()
+	waitFor(t, "jerry should be offline after disconnect", func() bool {
+		return !hub.Online("jerry") && hub.Count() == 1
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- hub.Shutdown(ctx)
+	}()
+	tom.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := tom.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatal("should be closed with going away:", err)
+	}
+	tom.Close()
+	if err := <-done; err != nil || hub.Count() != 0 {
+		t.Fatal("shutdown should wait connections:", err, hub.Count())
+	}
+	if _, res, err := websocket.DefaultDialer.Dial(url, http.Header{"Cookie": {"token1=ws1"}}); err == nil || res.StatusCode != 503 {
+		t.Fatal("closed hub should reject connections:", err)
+	}
+}
+
+//go test -run TestWsQueueFull -v
+func TestWsQueueFull(t *testing.T) {
+	hub := irisx.NewWsHub(irisx.WsHubOptions{SendQueue: 2})
+	app := newTestApp()
+	app.Get("/ws", hub.Handler)
+	server := serveTestApp(app)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	ws := dialWs(t, url, "")
+	defer ws.Close()
+	waitFor(t, "should be connected", func() bool {
+		return hub.Count() == 1
+	})
+	//a client that never reads, big messages fill socket buffers then the queue
+	big := make([]byte, 1<<20)
+	full := false
+	for i := 0; i < 64 && !full; i++ {
+		full = hub.Broadcast(big) == 0
+	}
+	if !full {
+		t.Fatal("queue should be full")
+	}
+	waitFor(t, "slow connection should be dropped", func() bool {
+		return hub.Count() == 0
+	})
+}